@@ -1,19 +1,30 @@
 package arex
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	dog "github.com/DataDog/zstd"
 	"github.com/a-h/generate"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/snappy"
 )
 
 func Test_BuildArexReport(t *testing.T) {
@@ -68,7 +79,7 @@ func Test_task(t *testing.T) {
 	// ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	// defer cancel()
 
-	batchGenerateByCtripAREX(context.TODO())
+	batchGenerateBySource(context.TODO())
 }
 
 func Test_ZSTD(t *testing.T) {
@@ -86,10 +97,12 @@ func Test_ZSTD(t *testing.T) {
 }
 
 func Test_CaseGenerate(t *testing.T) {
-	res := getTestCases("", "")
-	for _, oneCase := range res {
-		fmt.Println(oneCase.ToCaseText())
+	res := getTestCases("", time.Time{})
+	source, err := golangTestFile("arex", "", "http://localhost:8080", res)
+	if err != nil {
+		t.Fatal(err)
 	}
+	fmt.Println(string(source))
 
 }
 
@@ -119,6 +132,698 @@ func Test_GenerateStruct(t *testing.T) {
 }
 
 func Test_GeneratePostmanCase(t *testing.T) {
-	val := exportAREXToPostman("", "")
+	val := exportAREXToPostman("", time.Time{})
 	fmt.Println(val)
 }
+
+func Test_BuildOpenAPI(t *testing.T) {
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	schemas := []*schemaStore{
+		{Key: getAREXKey("shop", apiName), Schema: `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object",` +
+			`"properties":{"line":{"$ref":"#/definitions/line"},"parent":{"$ref":"#"}},` +
+			`"definitions":{"line":{"type":"object","properties":{"sku":{"type":"string"}}}}}`},
+		{Key: getAREXPartKey("shop", apiName, partRequest), Schema: `{"type":"object","properties":{"sku":{"type":"string"}}}`},
+		{Key: getAREXPartKey("shop", apiName, partQuery), Schema: `{"type":"object","required":["page"],"properties":{"page":{"type":"string"}}}`},
+		{Key: getAREXPartKey("shop", apiName, partHeaders), Schema: `{"type":"object","properties":{"x-trace":{"type":"string"}}}`},
+		{Key: "shopping-" + base64.URLEncoding.EncodeToString([]byte("/other")), Schema: `{}`},
+		{Key: getAREXKey("shop", base64.URLEncoding.EncodeToString([]byte("/api-orders"))), Schema: `{"type":"array"}`},
+	}
+	mockers := []*servletmocker{
+		{AppID: "shop", Method: "POST", Path: "/api/orders"},
+		{AppID: "shop", Method: "GET", Path: "/api/orders?page=1"},
+	}
+
+	doc, err := buildOpenAPI("shop", schemas, mockers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 2 {
+		t.Fatalf("only the paths of shop are exported: %v", doc.Paths)
+	}
+	post, get := doc.Paths["/api/orders"]["post"], doc.Paths["/api/orders"]["get"]
+	if post == nil || get == nil || post.RequestBody == nil || get.RequestBody != nil {
+		t.Fatalf("unexpected operations %+v %+v", post, get)
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].Name != "page" || !get.Parameters[0].Required ||
+		get.Parameters[1].In != "header" || get.Parameters[1].Required {
+		t.Errorf("unexpected parameters %+v", get.Parameters)
+	}
+	// the identifier of /api/orders is suffixed after /api-orders
+	if id := doc.Paths["/api-orders"]["get"].OperationID; id != "get_api_orders" || post.OperationID != "post_api_orders_2" {
+		t.Errorf("unexpected operation ids %s %s", id, post.OperationID)
+	}
+	if array := doc.Components.Schemas["api_orders_response"].(map[string]interface{}); array["type"] != "array" {
+		t.Errorf("unexpected response of /api-orders %v", array)
+	}
+	response := doc.Components.Schemas["api_orders_2_response"].(map[string]interface{})
+	properties := response["properties"].(map[string]interface{})
+	if ref := properties["line"].(map[string]interface{})["$ref"]; ref != "#/components/schemas/api_orders_2_response_line" {
+		t.Errorf("$defs should be hoisted into components: %v", ref)
+	}
+	if ref := properties["parent"].(map[string]interface{})["$ref"]; ref != "#/components/schemas/api_orders_2_response" {
+		t.Errorf("the root reference should refer the component: %v", ref)
+	}
+	if _, ok := doc.Components.Schemas["api_orders_2_request"]; !ok {
+		t.Error("request schema should be exported")
+	}
+}
+
+func Test_ServletParts(t *testing.T) {
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	for _, part := range []string{partResponse, partRequest, partHeaders, partQuery} {
+		path, p, ok := parseAREXKey(getAREXPartKey("my-shop", apiName, part), "my-shop")
+		if !ok || path != "/api/orders" || p != part {
+			t.Errorf("%q: parsed %s %q %v", part, path, p, ok)
+		}
+	}
+
+	headers := servletHeadersJSON(map[string]string{"Content-Type": "application/json", "Cookie": "sid=1"})
+	if string(headers) != `{"content-type":"application/json"}` {
+		t.Errorf("unexpected headers %s", headers)
+	}
+	query := servletQueryJSON("/api/orders?page=1&tag=a&tag=b")
+	if string(query) != `{"page":"1","tag":["a","b"]}` {
+		t.Errorf("unexpected query %s", query)
+	}
+	if servletQueryJSON("/api/orders") != nil || servletPath("/api/orders?page=1") != "/api/orders" {
+		t.Error("the path without query has no query schema")
+	}
+}
+
+func Test_MigratedSchema(t *testing.T) {
+	legacyKey, ok := legacyResponseKey("shop", "/api/orders?page=1")
+	if !ok || legacyKey != getAREXKey("shop", base64.URLEncoding.EncodeToString([]byte("/api/orders?page=1"))) {
+		t.Errorf("unexpected legacy key %s", legacyKey)
+	}
+	if _, ok := legacyResponseKey("shop", "/api/orders"); ok {
+		t.Error("the path without query has no legacy key")
+	}
+
+	legacy := &schemaStore{Key: legacyKey, State: schemaStateFrozen,
+		Schema: `{"type":"object","required":["id","note"],"properties":{"id":{"type":"integer"},"note":{"type":"string"}}}`}
+	migrated, err := migratedSchema(nil, legacy)
+	if err != nil || migrated == nil || migrated.Schema != legacy.Schema || migrated.State != schemaStateFrozen {
+		t.Fatalf("the legacy schema should be moved: %v %v", migrated, err)
+	}
+
+	current := &schemaStore{Key: "shop-L2FwaS9vcmRlcnM=",
+		Schema: `{"type":"object","required":["id"],"properties":{"id":{"type":"integer"},"total":{"type":"number"}}}`}
+	migrated, err = migratedSchema(current, legacy)
+	if err != nil || migrated == nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"note"`, `"total"`, `"required":["id"]`} {
+		if !strings.Contains(migrated.Schema, field) {
+			t.Errorf("%s is not merged: %s", field, migrated.Schema)
+		}
+	}
+
+	current.State = schemaStateFrozen
+	if migrated, err := migratedSchema(current, legacy); err != nil || migrated != nil {
+		t.Errorf("the frozen schema should be kept: %v %v", migrated, err)
+	}
+}
+
+func Test_GuardSample(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": {"type": "integer"},
+			"name": {"type": "string"},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	violations, err := serviceGuardSample(schema, []byte(`{"id": 2, "name": "pen", "tags": []}`))
+	if err != nil || len(violations) != 0 {
+		t.Errorf("the valid sample has violations %v %v", violations, err)
+	}
+	violations, err = serviceGuardSample(schema, []byte(`{"id": "3", "tags": [1]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations := make(map[string]bool)
+	for _, v := range violations {
+		locations[v.InstanceLocation] = true
+	}
+	for _, location := range []string{"", "/id", "/tags/0"} {
+		if !locations[location] {
+			t.Errorf("no violation at %q: %v", location, violations)
+		}
+	}
+
+	ss := &schemaStore{}
+	if ss.lifecycle() != schemaStateLearning || checkSchemaState(schemaStateFrozen) != nil || checkSchemaState("locked") == nil {
+		t.Error("unexpected lifecycle states")
+	}
+}
+
+func Test_ProposalReview(t *testing.T) {
+	current := `{"type": "object", "properties": {"id": {"type": "integer", "examples": [1]}}}`
+	proposed := `{"type": "object", "properties": {"id": {"type": "number", "examples": [1, 2.5], "x-percentiles": {"p50": 1}, "x-absent-samples": 2},
+		"name": {"type": "string"}}}`
+	diffs, err := serviceSchemaDiff(current, proposed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(map[string]*proposalDiff)
+	for _, d := range diffs {
+		changed[d.Path] = d
+	}
+	if len(changed) != 2 || changed["/properties/id/type"] == nil || changed["/properties/name"] == nil {
+		t.Fatalf("unexpected diffs %v", changed)
+	}
+	if d := changed["/properties/id/type"]; d.Current != `"integer"` || d.Proposed != `"number"` {
+		t.Errorf("unexpected diff %+v", d)
+	}
+	if d := changed["/properties/name"]; d.Current != "" || d.Proposed != `{"type":"string"}` {
+		t.Errorf("unexpected diff %+v", d)
+	}
+
+	p := &schemaProposal{Key: "shop", Status: proposalPending, Schema: proposed, Diffs: diffs}
+	if err := p.apply(&proposalReview{Action: reviewEdit, Reviewer: "ann", Schema: json.RawMessage(`{"type": 1}`)}, current); err == nil {
+		t.Error("the invalid edited schema is accepted")
+	}
+	if err := p.apply(&proposalReview{Action: reviewEdit, Reviewer: "ann", Schema: json.RawMessage(current)}, current); err != nil || len(p.Diffs) != 0 {
+		t.Errorf("edit: %v %v", err, p.Diffs)
+	}
+	if err := p.apply(&proposalReview{Action: reviewApprove, Reviewer: "bob", Comment: "lgtm"}, current); err != nil || p.Status != proposalApproved {
+		t.Errorf("approve: %v %s", err, p.Status)
+	}
+	if len(p.Reviews) != 2 || p.Reviews[1].Reviewer != "bob" || p.Reviews[1].Comment != "lgtm" {
+		t.Errorf("unexpected reviews %v", p.Reviews)
+	}
+	if err := p.apply(&proposalReview{Action: reviewReject, Reviewer: "ann"}, current); err != errProposalClosed {
+		t.Errorf("the approved proposal is reviewed again %v", err)
+	}
+
+	// the property named as an annotation is a change
+	diffs, err = serviceSchemaDiff(current, `{"type": "object", "properties": {"id": {"type": "integer"}, "examples": {"type": "array"}}}`)
+	if err != nil || len(diffs) != 1 || diffs[0].Path != "/properties/examples" {
+		t.Errorf("unexpected diffs %v %v", diffs, err)
+	}
+}
+
+func Test_ReplayPair(t *testing.T) {
+	gzipBase64 := func(text string) string {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(text))
+		w.Close()
+		return base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	cfg := replayConfig{mongoCollection: mongoCollection{Collection: "ReplayCompareResultNew"}}
+	if err := cfg.withDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	key, baseline, replay, err := cfg.pair(map[string]interface{}{
+		"_id":        "1",
+		"service":    "flight",
+		"resultname": "search",
+		"basemsg":    gzipBase64(`{"id": 1}`),
+		"testmsg":    gzipBase64(`{"id": "1"}`),
+	})
+	if err != nil || key != getAREXKey("flight", "search") || string(baseline) != `{"id": 1}` || string(replay) != `{"id": "1"}` {
+		t.Errorf("unexpected pair %s %s %s %v", key, baseline, replay, err)
+	}
+	if _, _, _, err := cfg.pair(map[string]interface{}{"service": "flight", "resultname": "search", "basemsg": "e30="}); err == nil {
+		t.Error("the pair without replay is read")
+	}
+
+	cfg = replayConfig{mongoCollection: mongoCollection{Collection: "pairs"}, KeyField: "key", Encoding: "none", BaselineField: "base", ReplayField: "test"}
+	if err := cfg.withDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	key, baseline, _, err = cfg.pair(map[string]interface{}{"key": "shop-api", "base": `{}`, "test": `[]`})
+	if err != nil || key != "shop-api" || string(baseline) != `{}` {
+		t.Errorf("unexpected pair %s %s %v", key, baseline, err)
+	}
+	for _, bad := range []replayConfig{
+		{},
+		{mongoCollection: mongoCollection{Collection: "c"}, Encoding: "lz4"},
+		{mongoCollection: mongoCollection{Collection: "c", URI: "mongodb://host"}},
+		{mongoCollection: mongoCollection{Collection: "c", Limit: maxCollectionLimit + 1}},
+	} {
+		if err := bad.withDefaults(); err == nil {
+			t.Errorf("invalid config %+v", bad)
+		}
+	}
+
+	// the replays are validated against the schema of all the baselines of the run
+	schema := ""
+	for _, baseline := range []string{`{"id": 1}`, `{"id": 2, "note": "x"}`, `{"id": 2.5}`} {
+		if schema, err = replayBaselineSchema(schema, false, []byte(baseline)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if violations, err := serviceGuardSample(schema, []byte(`{"id": 2.5, "note": "y"}`)); err != nil || len(violations) > 0 {
+		t.Errorf("the replay of the learned baselines fails %v %v", violations, err)
+	}
+	if frozen, _ := replayBaselineSchema(`{"type": "object"}`, true, []byte(`{"id": 1}`)); frozen != `{"type": "object"}` {
+		t.Errorf("the baseline is merged into the frozen schema %s", frozen)
+	}
+	if _, err := replayBaselineSchema(schema, false, []byte(`{`)); err == nil {
+		t.Error("the baseline that is not json is merged")
+	}
+	if conflicted, err := replayBaselineSchema(schema, false, []byte(`{"id": "A1"}`)); err == nil || conflicted != schema {
+		t.Errorf("the conflicting baseline is merged %v", err)
+	}
+
+	var report replayReport
+	for _, status := range []string{replayPassed, replayFailed, replayPassed, replayError} {
+		report.add(&replayCase{Status: status})
+	}
+	if report.Total != 4 || report.Passed != 2 || report.Failed != 1 || report.Errors != 1 {
+		t.Errorf("unexpected counts %+v", report)
+	}
+}
+
+func Test_RecordingSource(t *testing.T) {
+	t.Setenv(recordingSourceEnv, `{"type": "mongo", "uri": "mongodb://host", "database": "db", "collection": "records",
+		"serviceField": "service", "nameField": "resultname", "bodyField": "basemsg", "decoder": "gzip"}`)
+	if cfg, err := recordingSourceConfig(); err != nil || cfg.Collection != "records" || cfg.BodyField != "basemsg" {
+		t.Errorf("unexpected source config %+v %v", cfg, err)
+	}
+	t.Setenv(recordingSourceEnv, "")
+	if cfg, err := recordingSourceConfig(); cfg != nil || err != nil {
+		t.Errorf("the source is configured without %s", recordingSourceEnv)
+	}
+
+	dir, err := ioutil.TempDir("", "recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { recordingFileDir = old }(recordingFileDir)
+	recordingFileDir = dir
+
+	ndjson := `{"id": "r1", "service": "shop", "op": "search", "response": {"body": {"total": 1}}}
+{"service": "shop", "op": "search", "response": {"body": "eyJ0b3RhbCI6IDJ9"}}
+not json
+{"service": "shop", "response": {"body": {}}}
+`
+	ioutil.WriteFile(dir+"/shop.ndjson", []byte(ndjson), 0644)
+	cfg := sourceConfig{Type: sourceFile, Path: "shop.ndjson", Format: fileFormatNDJSON, fieldMapping: fieldMapping{
+		IDField: "id", ServiceField: "service", NameField: "op", BodyField: "response.body", Decoder: "base64",
+	}}
+	source, err := cfg.source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordings, err := source.Recordings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("unexpected recordings %v", recordings)
+	}
+	if r := recordings[0]; r.ID != "r1" || r.Key != getAREXKey("shop", "search") || string(r.Data) != `{"total":1}` {
+		t.Errorf("unexpected recording %s %s %s", r.ID, r.Key, r.Data)
+	}
+	if r := recordings[1]; r.ID != "shop.ndjson#2" || string(r.Data) != `{"total": 2}` {
+		t.Errorf("unexpected recording %s %s %s", r.ID, r.Key, r.Data)
+	}
+
+	har := `{"log": {"entries": [{
+		"request": {"method": "POST", "url": "https://shop.com/api/orders?page=1",
+			"headers": [{"name": ":authority", "value": "shop.com"}, {"name": "Accept", "value": "*/*"}],
+			"postData": {"mimeType": "application/json", "text": "{\"id\": 1}"}},
+		"response": {"status": 200, "content": {"mimeType": "application/json", "text": "eyJvayI6IHRydWV9", "encoding": "base64"}}
+	}, {
+		"request": {"method": "GET", "url": "https://shop.com/api/orders"},
+		"response": {"status": 500, "content": {"mimeType": "application/json", "text": "{\"error\": \"down\"}"}}
+	}]}}`
+	ioutil.WriteFile(dir+"/shop.har", []byte(har), 0644)
+	cfg = sourceConfig{Type: sourceFile, Path: "shop.har", Format: fileFormatHAR, AppID: "shop"}
+	if source, err = cfg.source(); err != nil {
+		t.Fatal(err)
+	}
+	if recordings, err = source.Recordings(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	expected := map[string]string{
+		getAREXPartKey("shop", apiName, partRequest):  `{"id": 1}`,
+		getAREXPartKey("shop", apiName, partHeaders):  `{"accept":"*/*"}`,
+		getAREXPartKey("shop", apiName, partQuery):    `{"page":"1"}`,
+		getAREXPartKey("shop", apiName, partResponse): `{"ok": true}`,
+	}
+	if len(recordings) != len(expected) {
+		t.Fatalf("unexpected recordings %v", recordings)
+	}
+	for _, r := range recordings {
+		if expected[r.Key] != string(r.Data) || r.ID != "shop.har#0" {
+			t.Errorf("unexpected recording %s %s %s", r.ID, r.Key, r.Data)
+		}
+	}
+
+	for _, bad := range []sourceConfig{
+		{Type: "kafka"},
+		{Type: sourceFile, Path: "../etc/passwd", Format: fileFormatNDJSON},
+		{Type: sourceFile, Path: "shop.har", Format: fileFormatHAR},
+		{Type: sourceMongo, mongoCollection: mongoCollection{Collection: "records"}, fieldMapping: fieldMapping{KeyField: "key"}},
+	} {
+		if _, err := bad.source(); err == nil {
+			t.Errorf("invalid config %+v", bad)
+		}
+	}
+}
+
+func Test_CodecRegistry(t *testing.T) {
+	payload := []byte(`{"id": 1, "name": "book"}`)
+	b64 := func(data []byte) []byte {
+		return []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	compress := func(w io.WriteCloser, buf *bytes.Buffer, data []byte) []byte {
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	zstdOf := func(data []byte) []byte {
+		res, _ := dog.Compress(nil, data)
+		return res
+	}
+	gzipOf := func(data []byte) []byte {
+		var buf bytes.Buffer
+		return compress(gzip.NewWriter(&buf), &buf, data)
+	}
+	snappyOf := func(data []byte) []byte {
+		var buf bytes.Buffer
+		return compress(snappy.NewBufferedWriter(&buf), &buf, data)
+	}
+	brotliOf := func(data []byte) []byte {
+		var buf bytes.Buffer
+		return compress(brotli.NewWriter(&buf), &buf, data)
+	}
+
+	for _, tc := range []struct {
+		data   []byte
+		chain  string
+		result string
+	}{
+		{payload, "", string(payload)},
+		{b64(zstdOf(payload)), "base64,zstd", string(payload)},
+		{b64(zstdOf(b64(payload))), "base64,zstd,base64", string(payload)},
+		{b64(gzipOf(payload)), "base64,gzip", string(payload)},
+		{snappyOf(payload), "snappy", string(payload)},
+		{brotliOf(payload), "brotli", string(payload)},
+		{b64(brotliOf(payload)), "base64,brotli", string(payload)},
+		{b64(zstdOf([]byte("a=1&b=2"))), "base64,zstd", "a=1&b=2"},
+		{[]byte("test"), "", "test"},
+	} {
+		res, chain, err := payloadCodecs.Decode(tc.data)
+		if err != nil || strings.Join(chain, ",") != tc.chain || string(res) != tc.result {
+			t.Errorf("%q: decoded %q by %v %v, expected %q by %s", tc.data, res, chain, err, tc.result, tc.chain)
+		}
+	}
+	if _, _, err := payloadCodecs.Decode([]byte{0xff, 0xfe, 0x00, 0x01}); err == nil {
+		t.Error("the binary payload is decoded")
+	}
+
+	// the json chain is preferred to the text chain found before it
+	r := &codecRegistry{}
+	sniffed := func(data []byte) bool { return string(data) == "payload" }
+	r.register(&payloadCodec{Name: "text", Sniff: sniffed, Decode: func([]byte) ([]byte, error) { return []byte("a=1"), nil }})
+	r.register(&payloadCodec{Name: "json", Sniff: sniffed, Decode: func([]byte) ([]byte, error) { return []byte(`{"a": 1}`), nil }})
+	if res, chain, err := r.Decode([]byte("payload")); err != nil || strings.Join(chain, ",") != "json" || string(res) != `{"a": 1}` {
+		t.Errorf("decoded %q by %v %v, expected the json chain", res, chain, err)
+	}
+}
+
+func Test_HARServletmockers(t *testing.T) {
+	text := `{"log": {"entries": [
+		{"startedDateTime": "2022-06-01T08:00:00.123Z",
+		 "request": {"method": "post", "url": "https://shop.com/api/orders?page=1",
+			"headers": [{"name": "Content-Type", "value": "application/json"}, {"name": "Cookie", "value": "sid=1"},
+				{"name": "authorization", "value": "Bearer t"}],
+			"postData": {"mimeType": "application/json", "text": "{\"id\": 1}"}},
+		 "response": {"status": 200, "content": {"mimeType": "application/json; charset=utf-8", "text": "{\"ok\": true}"}}},
+		{"request": {"method": "GET", "url": "https://shop.com/api/orders/9"},
+		 "response": {"status": 404, "content": {"mimeType": "application/json", "text": "{\"error\": \"not found\"}"}}},
+		{"request": {"method": "GET", "url": "https://shop.com/app.js"},
+		 "response": {"status": 200, "content": {"mimeType": "text/javascript", "text": "var a;"}}},
+		{"request": {"method": "GET", "url": "data:image/png;base64,AAAA"},
+		 "response": {"status": 200, "content": {"mimeType": "application/json", "text": "{}"}}},
+		{"request": {"method": "GET", "url": "https://shop.com/api/users"},
+		 "response": {"status": 200, "content": {"mimeType": "application/json", "text": "e30=!", "encoding": "base64"}}}
+	]}}`
+	var har harDocument
+	if err := json.Unmarshal([]byte(text), &har); err != nil {
+		t.Fatal(err)
+	}
+	mockers, skipped := harServletmockers(&har, "shop")
+	if len(mockers) != 1 || skipped != 4 {
+		t.Fatalf("unexpected mockers %d, skipped %d", len(mockers), skipped)
+	}
+	m := mockers[0]
+	if m.AppID != "shop" || m.Method != "POST" || m.Path != "/api/orders?page=1" || m.ID == "" ||
+		!m.CreateTime.Equal(time.Date(2022, 6, 1, 8, 0, 0, 123e6, time.UTC)) || m.RequestHeaders["Content-Type"] != "application/json" ||
+		len(m.RequestHeaders) != 1 {
+		t.Errorf("unexpected mocker %+v", m)
+	}
+	if request, err := unBase64andZstdString(m.Request); err != nil || string(request) != `{"id": 1}` {
+		t.Errorf("unexpected request %s %v", request, err)
+	}
+
+	recordings := servletMockerRecordings(mockers)
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	if len(recordings) != 4 || recordings[3].Key != getAREXKey("shop", apiName) || string(recordings[3].Data) != `{"ok": true}` {
+		t.Errorf("unexpected recordings %v", recordings)
+	}
+}
+
+func Test_PostmanFolders(t *testing.T) {
+	mockers := []*servletmocker{
+		{ID: "1", AppID: "shop", Method: "POST", Path: "/api/orders?page=1", Request: `{"id": 1}`, Response: []byte(`{"ok": true}`)},
+		{ID: "2", AppID: "shop", Method: "POST", Path: "/api/orders?page=2", Request: `{"id": 2}`},
+		{ID: "3", AppID: "shop", Method: "GET", Path: "/api/users", Status: 204},
+		{ID: "4", AppID: "cart", Method: "GET", Path: "/api/items"},
+		{ID: "5", AppID: "shop", Method: "POST", Path: "/api/orders?page=1", Request: `{"id": 1}`},
+	}
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	schemas := map[string]string{
+		getAREXKey("shop", apiName): `{"$schema": "http://json-schema.org/schema#", "type": "object"}`,
+	}
+	root := getPostmanRoot(mockers, schemas)
+	if root == nil || len(root.Item) != 2 {
+		t.Fatalf("unexpected app folders %v", root)
+	}
+	shop := root.Item[0].(*ItemGroup)
+	if shop.Name != "shop" || len(shop.Item) != 2 {
+		t.Fatalf("unexpected app folder %+v", shop)
+	}
+	orders := shop.Item[0].(*ItemGroup)
+	if orders.Name != "/api/orders" || len(orders.Item) != 2 {
+		t.Fatalf("unexpected path folder %+v", orders)
+	}
+
+	item := orders.Item[0].(*Item)
+	if len(item.Response) != 1 || item.Response[0].Body != `{"ok": true}` || item.Response[0].Code != 200 {
+		t.Errorf("unexpected example %+v", item.Response)
+	}
+	exec := strings.Join(item.Event[0].Script.Exec.([]string), "\n")
+	if item.Event[0].Listen != "test" || !strings.Contains(exec, `pm.response.to.have.status(200)`) ||
+		!strings.Contains(exec, `const schema = {"$schema":"http://json-schema.org/draft-07/schema#","type":"object"};`) ||
+		!strings.Contains(exec, "ajv.validate(schema, pm.response.json())") {
+		t.Errorf("unexpected test script %s", exec)
+	}
+	// the path without learned schema only asserts the status
+	users := shop.Item[1].(*ItemGroup).Item[0].(*Item)
+	if exec := strings.Join(users.Event[0].Script.Exec.([]string), "\n"); strings.Contains(exec, "ajv") || len(users.Response) != 0 ||
+		!strings.Contains(exec, `pm.response.to.have.status(204)`) {
+		t.Errorf("unexpected item of users %s %+v", exec, users.Response)
+	}
+
+	if _, err := root.MarshalJSON(); err != nil {
+		t.Error(err)
+	}
+
+	// the tuples and $defs of draft 2020-12 are spelled by draft 7 for ajv
+	tuple, ok := postmanSchema(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object", "properties": {
+		"point": {"type": "array", "prefixItems": [{"type": "string"}, {"type": "number"}], "items": false},
+		"line": {"$ref": "#/$defs/line"}}, "$defs": {"line": {"type": "object"}}}`)
+	for _, expected := range []string{`"items":[{"type":"string"},{"type":"number"}],"additionalItems":false`,
+		`"$ref":"#/definitions/line"`, `"definitions":{"line":`} {
+		if !ok || !strings.Contains(tuple, expected) {
+			t.Errorf("%s is not in the draft 7 schema %s", expected, tuple)
+		}
+	}
+}
+
+func Test_PostmanImport(t *testing.T) {
+	text := `{"info": {"name": "shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+		"item": [
+			{"name": "orders", "item": [
+				{"name": "create", "request": {"method": "POST", "url": {"raw": "{{base_url}}/api/orders?page=1", "host": ["{{base_url}}"], "path": ["api", "orders"]},
+					"header": [{"key": "Content-Type", "value": "application/json"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+					"body": {"mode": "raw", "raw": "{\"id\": 1}"}},
+				 "response": [{"name": "text", "code": 400, "body": "bad"}, {"name": "ok", "code": 201, "body": "{\"ok\": true}"}, {"name": "text", "code": 500, "body": "oops"},
+					{"name": "failed", "code": 200, "body": "{\"ok\": false, \"reason\": \"sold out\"}"}]},
+				{"name": "deep", "item": [{"name": "list", "request": {"url": "https://shop.com/api/orders/list"}}]},
+				{"name": "short", "request": "https://shop.com/api/orders/short"}
+			]},
+			{"name": "no path", "request": {"url": "{{base_url}}"}}
+		]}`
+	var root Root
+	if err := json.Unmarshal([]byte(text), &root); err != nil {
+		t.Fatal(err)
+	}
+	items, err := postmanItems(root.Item)
+	if err != nil || len(items) != 4 {
+		t.Fatalf("unexpected items %d %v", len(items), err)
+	}
+
+	mocker, recordings, err := postmanServletmocker(items[0], "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mocker.Method != "POST" || mocker.Path != "/api/orders?page=1" || len(mocker.RequestHeaders) != 1 || mocker.Status != 201 {
+		t.Errorf("unexpected mocker %+v", mocker)
+	}
+	if response, err := unBase64andZstdString(string(mocker.Response)); err != nil || string(response) != `{"ok": true}` {
+		t.Errorf("unexpected response %s %v", response, err)
+	}
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	keys := make([]string, 0, len(recordings))
+	for _, r := range recordings {
+		keys = append(keys, r.Key)
+	}
+	expected := []string{getAREXPartKey("shop", apiName, partRequest), getAREXPartKey("shop", apiName, partHeaders),
+		getAREXPartKey("shop", apiName, partQuery), getAREXKey("shop", apiName), getAREXKey("shop", apiName)}
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected recording keys %v", keys)
+	}
+
+	if mocker, _, err := postmanServletmocker(items[1], "shop"); err != nil || mocker.Method != "GET" || mocker.Path != "/api/orders/list" {
+		t.Errorf("unexpected mocker %+v %v", mocker, err)
+	}
+	if mocker, _, err := postmanServletmocker(items[2], "shop"); err != nil || mocker.Method != "GET" || mocker.Path != "/api/orders/short" {
+		t.Errorf("unexpected mocker of the url request %+v %v", mocker, err)
+	}
+	if _, _, err := postmanServletmocker(items[3], "shop"); err == nil {
+		t.Error("the item without path is imported")
+	}
+
+	// the exported collection is imported
+	exported := getPostmanRoot([]*servletmocker{mocker, {ID: "2", AppID: "shop", Method: "GET", Path: "/api/users"}}, nil)
+	data, err := exported.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := postmanItems(root.Item); err != nil || len(items) != 2 || postmanPath(&items[1].Request) != "/api/users" {
+		t.Errorf("unexpected items of the exported collection %v", err)
+	}
+}
+
+func Test_GolangTestFile(t *testing.T) {
+	mockers := []*servletmocker{
+		{ID: "1", AppID: "shop-web", Method: "POST", Path: "/api/orders?page=1", Request: `{"name": "` + "`book`" + `"}`,
+			RequestHeaders: map[string]string{"Content-Type": "application/json", "Host": "shop.com", "Content-Length": "10"}, Response: []byte(`{"ok": true}`)},
+		{ID: "2", AppID: "shop-web", Method: "GET", Path: "/api/orders?page=2", Response: []byte(`{"ok": true}`)},
+		{ID: "3", AppID: "shop-web", Method: "GET", Path: "/api/users", Response: []byte(`{"users": []}`)},
+		{ID: "4", AppID: "shop-web", Method: "GET", Path: "/health"},
+		{ID: "5", AppID: "shop-web", Method: "GET", Path: "/api/orders/2"},
+	}
+	apiName := base64.URLEncoding.EncodeToString([]byte("/api/orders"))
+	schemas := map[string]string{
+		getAREXKey("shop-web", apiName): `{"$schema": "http://json-schema.org/schema#", "type": "object", "required": ["ok"]}`,
+	}
+	cases := servletTestCases(mockers, schemas)
+	if len(cases) != 5 || cases[4].Title != "shop_web_api_orders_2_2" || cases[0].Title != "shop_web_api_orders" || cases[1].Title != "shop_web_api_orders_2" ||
+		len(cases[0].Headers) != 1 || cases[0].Body != "{\"name\": \"`book`\"}" || cases[0].Schema == "" ||
+		cases[2].Schema != "" || cases[2].Response != `{"users": []}` {
+		t.Fatalf("unexpected testcases %+v", cases)
+	}
+	for start, expected := range map[string]time.Time{
+		"":           {},
+		"2022-02-22": time.Date(2022, 2, 22, 0, 0, 0, 0, time.UTC),
+		"2022-2-2":   time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC),
+	} {
+		if startTime, err := parseStartDate(start); err != nil || !startTime.Equal(expected) {
+			t.Errorf("%q: parsed %v %v", start, startTime, err)
+		}
+	}
+	if _, err := parseStartDate("2022/02/22"); err == nil {
+		t.Error("the invalid start date is parsed")
+	}
+
+	source, err := golangTestFile("shop-web", "", "http://localhost:8080", cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(source)
+	for _, expected := range []string{"package shop_web", "func Test_shop_web_api_orders_2(t *testing.T)",
+		`arexValidate(t, body, `, "arexCompare(t, body, `{\"users\": []}`)", `"github.com/arextest/arexAnalysis/jsonschema"`} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("%s is not generated in\n%s", expected, text)
+		}
+	}
+
+	// the file compiles
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "shop_web_arex_test.go", source, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("shop_web", fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("%v\n%s", err, text)
+	}
+}
+
+func Test_TestcaseExporters(t *testing.T) {
+	mockers := []*servletmocker{
+		{ID: "1", AppID: "shop", Method: "POST", Path: "/api/orders?page=1&size=2", Request: `{"name": "O'Reilly <books>"}`,
+			RequestHeaders: map[string]string{"Content-Type": "application/json"}, Response: []byte(`{"ok": true}`)},
+		{ID: "2", AppID: "shop", Method: "GET", Path: "/api/users"},
+		{ID: "3", AppID: "shop", Method: "POST", Path: "/api/notes", Request: "note\n### GET http://evil.com", Status: 201,
+			RequestHeaders: map[string]string{"X-Note": "a\r\nX-Injected: 1"}},
+	}
+	cases := servletTestCases(mockers, nil)
+	opts := &exportOptions{BaseURL: "https://shop.com:8443/v1/"}
+	expected := map[string][]string{
+		"k6": {`const BASE_URL = __ENV.BASE_URL || "https://shop.com:8443/v1";`,
+			`res = http.request("POST", BASE_URL + "/api/orders?page=1&size=2", "{\"name\": \"O'Reilly <books>\"}", { headers: {"Content-Type":"application/json"} });`,
+			`"shop_api_orders status is 200": (r) => r.status === 200,`, `"shop_api_orders response is json": isJSON,`,
+			`res = http.request("GET", BASE_URL + "/api/users", null, { headers: {} });`,
+			`"shop_api_notes status is 201": (r) => r.status === 201,`},
+		"jmeter": {`<stringProp name="HTTPSampler.path">/v1/api/orders?page=1&amp;size=2</stringProp>`,
+			`<stringProp name="Argument.value">{&#34;name&#34;: &#34;O&#39;Reilly &lt;books&gt;&#34;}</stringProp>`,
+			`<stringProp name="Argument.value">${__P(port,8443)}</stringProp>`, `<stringProp name="Header.name">Content-Type</stringProp>`},
+		"curl": {`[ -n "$BASE_URL" ] || BASE_URL='https://shop.com:8443/v1'`, `expect 'shop_api_orders' 200 -X 'POST' \`,
+			`--data-raw '{"name": "O'\''Reilly <books>"}' \`, `"$BASE_URL"'/api/orders?page=1&size=2'`},
+		"http": {"@baseUrl = https://shop.com:8443/v1", "POST {{baseUrl}}/api/orders?page=1&size=2\nContent-Type: application/json\n\n{\"name\": \"O'Reilly <books>\"}\n",
+			"### shop_api_users replays the recording 2", "X-Note: a X-Injected: 1\n\nnote\n ### GET http://evil.com\n"},
+		"golang": {"func Test_shop_api_orders(t *testing.T)"},
+	}
+	for format, exporter := range testcaseExporters {
+		data, err := exporter.Export("shop", cases, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for _, text := range expected[format] {
+			if !bytes.Contains(data, []byte(text)) {
+				t.Errorf("%s: %s is not exported in\n%s", format, text, data)
+			}
+		}
+		if format == "jmeter" {
+			decoder := xml.NewDecoder(bytes.NewReader(data))
+			for {
+				if _, err := decoder.Token(); err != nil {
+					if err != io.EOF {
+						t.Errorf("jmeter: %v", err)
+					}
+					break
+				}
+			}
+		}
+	}
+	if _, err := testcaseExporters["k6"].Export("shop", cases, &exportOptions{BaseURL: "shop.com"}); err == nil {
+		t.Error("the base url without host is exported")
+	}
+}
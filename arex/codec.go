@@ -0,0 +1,172 @@
+package arex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	maxCodecChain   = 6        // the most codecs applied to a payload
+	maxDecodedBytes = 64 << 20 // the most bytes of a decoded layer
+)
+
+// magic bytes of the compressed formats
+var (
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic   = []byte{0x1f, 0x8b}
+	snappyMagic = []byte("\xff\x06\x00\x00sNaPpY") // the stream identifier of the framing format
+)
+
+// payloadCodec a layer of the encoding of recorded payload
+type payloadCodec struct {
+	Name   string
+	Sniff  func(data []byte) bool // whether the payload may be of this codec
+	Decode func(data []byte) ([]byte, error)
+}
+
+// codecRegistry the codecs of payload, the sniffed codecs are tried in the order of registration
+type codecRegistry struct {
+	codecs []*payloadCodec
+}
+
+func (r *codecRegistry) register(c *payloadCodec) {
+	r.codecs = append(r.codecs, c)
+}
+
+// payloadCodecs the codecs of the recorded bodies
+var payloadCodecs = newCodecRegistry()
+
+func newCodecRegistry() *codecRegistry {
+	r := &codecRegistry{}
+	r.register(&payloadCodec{Name: "zstd", Sniff: hasMagic(zstdMagic), Decode: zstdDecode})
+	r.register(&payloadCodec{Name: "gzip", Sniff: hasMagic(gzipMagic), Decode: func(data []byte) ([]byte, error) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return readLimited(reader)
+	}})
+	r.register(&payloadCodec{Name: "snappy", Sniff: hasMagic(snappyMagic), Decode: func(data []byte) ([]byte, error) {
+		return readLimited(snappy.NewReader(bytes.NewReader(data)))
+	}})
+	r.register(&payloadCodec{Name: "base64", Sniff: isBase64, Decode: func(data []byte) ([]byte, error) {
+		text := bytes.TrimSpace(data)
+		if bytes.ContainsAny(text, "-_") {
+			return base64.URLEncoding.DecodeString(string(text))
+		}
+		return base64.StdEncoding.DecodeString(string(text))
+	}})
+	// brotli has no magic bytes, it is tried on the binary payloads
+	r.register(&payloadCodec{Name: "brotli", Sniff: func(data []byte) bool { return !utf8.Valid(data) }, Decode: func(data []byte) ([]byte, error) {
+		return readLimited(brotli.NewReader(bytes.NewReader(data)))
+	}})
+	return r
+}
+
+// quality of the decoded payload, the better chain is preferred
+const (
+	decodedNone = iota
+	decodedText
+	decodedJSON
+)
+
+// Decode sniff the layers of payload and decode them, returns the payload and the names of the applied codecs in order.
+// the chain that decodes to json is preferred, then the first chain that decodes to text
+func (r *codecRegistry) Decode(data []byte) ([]byte, []string, error) {
+	decoded, chain, quality := r.decode(data, 0)
+	if quality == decodedNone {
+		return nil, nil, errors.New("no codec decodes the payload to json or text")
+	}
+	return decoded, chain, nil
+}
+
+func (r *codecRegistry) decode(data []byte, depth int) ([]byte, []string, int) {
+	if json.Valid(data) {
+		return data, nil, decodedJSON
+	}
+	var text []byte
+	var textChain []string
+	if depth < maxCodecChain {
+		for _, c := range r.codecs {
+			if !c.Sniff(data) {
+				continue
+			}
+			decoded, err := c.Decode(data)
+			if err != nil || len(decoded) == 0 {
+				continue
+			}
+			res, chain, quality := r.decode(decoded, depth+1)
+			switch {
+			case quality == decodedJSON:
+				return res, append([]string{c.Name}, chain...), decodedJSON
+			case quality == decodedText && text == nil:
+				text, textChain = res, append([]string{c.Name}, chain...)
+			}
+		}
+	}
+	if text != nil {
+		return text, textChain, decodedText
+	}
+	// the text that no codec decodes, such as form body
+	if utf8.Valid(data) {
+		return data, nil, decodedText
+	}
+	return nil, nil, decodedNone
+}
+
+// decodePayload decode the recorded body by the sniffed codecs
+func decodePayload(in string) ([]byte, error) {
+	data, _, err := payloadCodecs.Decode([]byte(in))
+	return data, err
+}
+
+func hasMagic(magic []byte) func(data []byte) bool {
+	return func(data []byte) bool {
+		return bytes.HasPrefix(data, magic)
+	}
+}
+
+// isBase64 whether the payload is in the alphabet of standard or url base64, with the padded length
+func isBase64(data []byte) bool {
+	text := bytes.TrimSpace(data)
+	if len(text) < 4 || len(text)%4 != 0 {
+		return false
+	}
+	for i, c := range text {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/', c == '-', c == '_':
+		case c == '=' && i >= len(text)-2:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxDecodedBytes))
+
+func zstdDecode(data []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(data, nil)
+}
+
+func readLimited(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxDecodedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDecodedBytes {
+		return nil, errors.New("the decoded payload is too large")
+	}
+	return data, nil
+}
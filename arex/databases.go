@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"sync"
 	"time"
 
+	"github.com/arextest/arexAnalysis/jsonschema"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
@@ -15,7 +18,10 @@ type schemaStore struct {
 	ID         bson.ObjectId `json:"_id,omitempty"`
 	Key        string        `json:"key"`
 	Schema     string        `json:"schema"`
+	State      string        `json:"state,omitempty"` // lifecycle state, learning when empty
 	LastUpdate time.Time     `json:"lastupdate"`
+	// Absent the drift bookkeeping of the learned schema, it is not a part of the schema
+	Absent jsonschema.AbsentSamples `json:"-" bson:"absent,omitempty"`
 }
 
 var mongoDatabase *mongo.Database
@@ -56,7 +62,7 @@ func saveSchema(ctx context.Context, item schemaStore) {
 	scs := db.Collection(schemaCollectionName)
 
 	filter := bson.M{"key": item.Key}
-	update := bson.M{"$set": bson.M{"schema": item.Schema, "lastupdate": time.Now()}}
+	update := bson.M{"$set": bson.M{"schema": item.Schema, "absent": item.Absent, "lastupdate": time.Now()}}
 
 	// result, err := scs.InsertOne(ctx, item)
 	result, err := scs.UpdateOne(ctx, filter, update, opts)
@@ -119,6 +125,50 @@ func querySchemas(ctx context.Context, limit int64) *map[string]*schemaStore {
 	return &schemaMap
 }
 
+// querySchemasByPrefix query the schemas whose key starts with prefix, such as the schemas of an app
+func querySchemasByPrefix(ctx context.Context, prefix string) []*schemaStore {
+	db := ConnectOfMongoDB()
+	scs := db.Collection(schemaCollectionName)
+
+	filter := bson.M{"key": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+	cursor, err := scs.Find(ctx, filter)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var results []bson.M
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil
+	}
+	schemas := make([]*schemaStore, 0, len(results))
+	for _, oneM := range results {
+		var schema schemaStore
+		bsonBytes, _ := bson.Marshal(oneM)
+		if err := bson.Unmarshal(bsonBytes, &schema); err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+		schemas = append(schemas, &schema)
+	}
+	return schemas
+}
+
+// saveSchemaState set the lifecycle state of the existed schema, returns false when the schema is not found
+func saveSchemaState(ctx context.Context, key, state string) bool {
+	db := ConnectOfMongoDB()
+	scs := db.Collection(schemaCollectionName)
+
+	filter := bson.M{"key": key}
+	update := bson.M{"$set": bson.M{"state": state}}
+	result, err := scs.UpdateOne(ctx, filter, update)
+	if err != nil {
+		fmt.Printf("save schema state failed %s\n", err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
 func delteSchemaData(ctx context.Context, key string) bool {
 	db := ConnectOfMongoDB()
 	scs := db.Collection(schemaCollectionName)
@@ -131,3 +181,274 @@ func delteSchemaData(ctx context.Context, key string) bool {
 	fmt.Printf("delete count %d\n", res.DeletedCount)
 	return true
 }
+
+const driftCollectionName string = "drifts"
+
+// driftRecord the drift event of schema key, with the recording that caused it
+type driftRecord struct {
+	Key      string    `json:"key" bson:"key"`
+	Kind     string    `json:"kind" bson:"kind"`
+	Path     string    `json:"path" bson:"path"`
+	Before   string    `json:"before,omitempty" bson:"before,omitempty"`
+	After    string    `json:"after,omitempty" bson:"after,omitempty"`
+	RecordID string    `json:"recordid,omitempty" bson:"recordid,omitempty"`
+	Time     time.Time `json:"time" bson:"time"`
+}
+
+func saveDriftRecords(ctx context.Context, records []*driftRecord) {
+	if len(records) == 0 {
+		return
+	}
+	db := ConnectOfMongoDB()
+	dcs := db.Collection(driftCollectionName)
+
+	docs := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		docs = append(docs, record)
+	}
+	if _, err := dcs.InsertMany(ctx, docs); err != nil {
+		fmt.Printf("save drift events failed %s\n", err)
+	}
+}
+
+// queryDriftRecords query the drift events of key, the newest first
+func queryDriftRecords(ctx context.Context, key string, limit int64) []*driftRecord {
+	db := ConnectOfMongoDB()
+	dcs := db.Collection(driftCollectionName)
+
+	filter := bson.M{"key": key}
+	opts := options.Find().SetSort(bson.M{"time": -1}).SetLimit(limit)
+	cursor, err := dcs.Find(ctx, filter, opts)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	records := make([]*driftRecord, 0)
+	if err = cursor.All(ctx, &records); err != nil {
+		fmt.Printf("%v\n", err)
+		return nil
+	}
+	return records
+}
+
+const violationCollectionName string = "violations"
+
+// violationRecord the violations of a recording validated against the frozen schema of key
+type violationRecord struct {
+	Key        string            `json:"key" bson:"key"`
+	RecordID   string            `json:"recordid,omitempty" bson:"recordid,omitempty"`
+	Violations []schemaViolation `json:"violations" bson:"violations"`
+	Time       time.Time         `json:"time" bson:"time"`
+}
+
+func saveViolationRecord(ctx context.Context, record *violationRecord) {
+	db := ConnectOfMongoDB()
+	vcs := db.Collection(violationCollectionName)
+
+	if _, err := vcs.InsertOne(ctx, record); err != nil {
+		fmt.Printf("save violations failed %s\n", err)
+	}
+}
+
+// queryViolationRecords query the violations of key, the newest first
+func queryViolationRecords(ctx context.Context, key string, limit int64) []*violationRecord {
+	db := ConnectOfMongoDB()
+	vcs := db.Collection(violationCollectionName)
+
+	filter := bson.M{"key": key}
+	opts := options.Find().SetSort(bson.M{"time": -1}).SetLimit(limit)
+	cursor, err := vcs.Find(ctx, filter, opts)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	records := make([]*violationRecord, 0)
+	if err = cursor.All(ctx, &records); err != nil {
+		fmt.Printf("%v\n", err)
+		return nil
+	}
+	return records
+}
+
+const proposalCollectionName string = "proposals"
+
+var proposalIndexOnce sync.Once
+
+// saveProposal save the proposal when the saved one is of the same version, and bump the version.
+// returns errProposalConflict when the proposal is saved by another merge or review since it was read,
+// a key has only one pending proposal by the unique index
+func saveProposal(ctx context.Context, proposal *schemaProposal) error {
+	db := ConnectOfMongoDB()
+	pcs := db.Collection(proposalCollectionName)
+	proposalIndexOnce.Do(func() {
+		index := mongo.IndexModel{
+			Keys:    bson.M{"key": 1},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": proposalPending}),
+		}
+		if _, err := pcs.Indexes().CreateOne(ctx, index); err != nil {
+			fmt.Printf("create proposal index failed %s\n", err)
+		}
+	})
+
+	filter := bson.M{"_id": proposal.ID, "version": proposal.Version}
+	if proposal.Version == 0 {
+		// the new proposal, or the proposal saved before the versions
+		filter["version"] = bson.M{"$in": []interface{}{0, nil}}
+	}
+	proposal.Version++
+	// the upsert of the stale version fails by the unique _id, and the second pending proposal of key by the unique index
+	_, err := pcs.ReplaceOne(ctx, filter, proposal, options.Replace().SetUpsert(true))
+	if err != nil {
+		proposal.Version--
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return errProposalConflict
+	}
+	return err
+}
+
+func queryProposal(ctx context.Context, id string) *schemaProposal {
+	db := ConnectOfMongoDB()
+	pcs := db.Collection(proposalCollectionName)
+
+	var proposal schemaProposal
+	if err := pcs.FindOne(ctx, bson.M{"_id": id}).Decode(&proposal); err != nil {
+		return nil
+	}
+	return &proposal
+}
+
+// queryPendingProposal the pending proposal of key, nil when there is none
+func queryPendingProposal(ctx context.Context, key string) *schemaProposal {
+	db := ConnectOfMongoDB()
+	pcs := db.Collection(proposalCollectionName)
+
+	var proposal schemaProposal
+	filter := bson.M{"key": key, "status": proposalPending}
+	if err := pcs.FindOne(ctx, filter).Decode(&proposal); err != nil {
+		return nil
+	}
+	return &proposal
+}
+
+// queryProposals query the proposals by key and status, the empty ones match all. the newest first
+func queryProposals(ctx context.Context, key, status string, limit int64) []*schemaProposal {
+	db := ConnectOfMongoDB()
+	pcs := db.Collection(proposalCollectionName)
+
+	filter := bson.M{}
+	if key != "" {
+		filter["key"] = key
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.M{"updated": -1}).SetLimit(limit)
+	cursor, err := pcs.Find(ctx, filter, opts)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	proposals := make([]*schemaProposal, 0)
+	if err = cursor.All(ctx, &proposals); err != nil {
+		fmt.Printf("%v\n", err)
+		return nil
+	}
+	return proposals
+}
+
+const replayReportCollectionName string = "replayreports"
+const replayCaseCollectionName string = "replaycases"
+
+func saveReplayReport(ctx context.Context, report *replayReport) error {
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayReportCollectionName)
+
+	opts := options.Replace().SetUpsert(true)
+	_, err := rcs.ReplaceOne(ctx, bson.M{"_id": report.ID}, report, opts)
+	return err
+}
+
+// saveReplayReportError set the status of the report error, when the report can not be saved
+func saveReplayReportError(ctx context.Context, id string, cause error) error {
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayReportCollectionName)
+
+	update := bson.M{"$set": bson.M{"status": replayError, "error": cause.Error(), "finished": time.Now()}}
+	_, err := rcs.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// saveReplayCases save the cases of the report, one document a case
+func saveReplayCases(ctx context.Context, reportID string, cases []*replayCase) error {
+	if len(cases) == 0 {
+		return nil
+	}
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayCaseCollectionName)
+
+	docs := make([]interface{}, 0, len(cases))
+	for _, c := range cases {
+		c.ReportID = reportID
+		docs = append(docs, c)
+	}
+	_, err := rcs.InsertMany(ctx, docs)
+	return err
+}
+
+func queryReplayReport(ctx context.Context, id string) *replayReport {
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayReportCollectionName)
+
+	var report replayReport
+	if err := rcs.FindOne(ctx, bson.M{"_id": id}).Decode(&report); err != nil {
+		return nil
+	}
+	return &report
+}
+
+// queryReplayCases query the cases of the report, only the cases of status when it is not empty
+func queryReplayCases(ctx context.Context, reportID string, status string) []*replayCase {
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayCaseCollectionName)
+
+	filter := bson.M{"reportid": reportID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := rcs.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	cases := make([]*replayCase, 0)
+	if err = cursor.All(ctx, &cases); err != nil {
+		fmt.Printf("%v\n", err)
+		return nil
+	}
+	return cases
+}
+
+// queryReplayReports query the reports, the newest first
+func queryReplayReports(ctx context.Context, limit int64) []*replayReport {
+	db := ConnectOfMongoDB()
+	rcs := db.Collection(replayReportCollectionName)
+
+	opts := options.Find().SetSort(bson.M{"started": -1}).SetLimit(limit)
+	cursor, err := rcs.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	reports := make([]*replayReport, 0)
+	if err = cursor.All(ctx, &reports); err != nil {
+		fmt.Printf("%v\n", err)
+		return nil
+	}
+	return reports
+}
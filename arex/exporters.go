@@ -0,0 +1,129 @@
+package arex
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// exportOptions the options of exporting the testcases of app
+type exportOptions struct {
+	BaseURL string // the url of the service under test
+	Package string // the go package, the app id by default
+}
+
+// testcaseExporter render the testcases of app as the script of a test tool
+type testcaseExporter interface {
+	// Export the file of the testcases
+	Export(appid string, cases []*testcase, opts *exportOptions) ([]byte, error)
+	// ContentType of the file
+	ContentType() string
+	// FileName of the file of app
+	FileName(appid string) string
+}
+
+// testcaseExporters the exporters by format of /testcases/:format/:appid
+var testcaseExporters = map[string]testcaseExporter{
+	"golang": golangExporter{},
+	"k6":     &templateExporter{template: "k6.tmpl", contentType: "application/javascript", extension: ".k6.js"},
+	"jmeter": &templateExporter{template: "jmeter.tmpl", contentType: "application/xml", extension: ".jmx"},
+	"curl":   &templateExporter{template: "curl.tmpl", contentType: "text/x-shellscript", extension: ".sh"},
+	"http":   &templateExporter{template: "http.tmpl", contentType: "text/plain", extension: ".http"},
+}
+
+// golangExporter the _test.go file of golangTestFile
+type golangExporter struct{}
+
+func (golangExporter) Export(appid string, cases []*testcase, opts *exportOptions) ([]byte, error) {
+	return golangTestFile(appid, opts.Package, opts.BaseURL, cases)
+}
+
+func (golangExporter) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (golangExporter) FileName(appid string) string {
+	return goIdentifier(appid) + "_arex_test.go"
+}
+
+// templateExporter the file rendered by the template in templateDirs
+type templateExporter struct {
+	template    string
+	contentType string
+	extension   string
+}
+
+// exportFuncs quote the values in the templates of exporters
+var exportFuncs = template.FuncMap{
+	// js the javascript literal of v
+	"js": func(v interface{}) (string, error) {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		err := encoder.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n"), err
+	},
+	"xml": func(v interface{}) (string, error) {
+		var buf bytes.Buffer
+		err := xml.EscapeText(&buf, []byte(fmt.Sprint(v)))
+		return buf.String(), err
+	},
+	// shell the single quoted word of posix shell
+	"shell": func(v interface{}) string {
+		return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", `'\''`) + "'"
+	},
+	// httpLine the text on a line of the http file, the line breaks are folded into spaces
+	"httpLine": func(v interface{}) string {
+		return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(fmt.Sprint(v))
+	},
+	// httpBody the body of the http file, the lines starting with ### are indented that they do not open a request
+	"httpBody": func(v interface{}) string {
+		lines := strings.Split(fmt.Sprint(v), "\n")
+		for i, line := range lines {
+			if strings.HasPrefix(line, "###") {
+				lines[i] = " " + line
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+}
+
+func (e *templateExporter) Export(appid string, cases []*testcase, opts *exportOptions) ([]byte, error) {
+	tmpl, err := parseTemplate(e.template, exportFuncs)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", opts.BaseURL)
+	}
+	data := struct {
+		App, BaseURL, Scheme, Host, Port, Path string
+		Cases                                  []*testcase
+	}{
+		App:     appid,
+		BaseURL: strings.TrimSuffix(opts.BaseURL, "/"),
+		Scheme:  base.Scheme,
+		Host:    base.Hostname(),
+		Port:    base.Port(),
+		Path:    strings.TrimSuffix(base.Path, "/"),
+		Cases:   cases,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *templateExporter) ContentType() string {
+	return e.contentType + "; charset=utf-8"
+}
+
+func (e *templateExporter) FileName(appid string) string {
+	return goIdentifier(appid) + e.extension
+}
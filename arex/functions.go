@@ -1,9 +1,13 @@
 package arex
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/arextest/arexAnalysis/comparer"
 	"github.com/arextest/arexAnalysis/jsonschema"
@@ -15,6 +19,51 @@ func serviceGenerateSchema(data []byte) (*jsonschema.SchemaDataModel, error) {
 	return schemaDoc, nil
 }
 
+// generateOptions options of json-schema generation, bind from url query
+type generateOptions struct {
+	Draft       string  `form:"draft"`
+	Defs        bool    `form:"defs"`
+	Formats     string  `form:"formats"` // comma separated formats to detect in order, none to disable
+	Numeric     string  `form:"numeric"` // exact, range or none
+	Padding     float64 `form:"padding"` // padding ratio of range mode
+	MultipleOf  bool    `form:"multipleOf"`
+	Percentiles bool    `form:"percentiles"`
+	Pattern     bool    `form:"pattern"`
+}
+
+// serviceGenerateSchemaByOptions input: json output: json-schema generated by options
+func serviceGenerateSchemaByOptions(data []byte, opts generateOptions) (*jsonschema.SchemaDataModel, error) {
+	m := jsonschema.NewSchemaDataModel(data, "arex")
+	if opts.Draft != "" {
+		version, err := jsonschema.DraftVersion(opts.Draft)
+		if err != nil {
+			return nil, err
+		}
+		m.SetDraft(version)
+	}
+	m.Reuse = opts.Defs
+	switch opts.Formats {
+	case "":
+	case "none":
+		m.Format = false
+	default:
+		m.DetectFormats = strings.Split(opts.Formats, ",")
+		if err := jsonschema.CheckDetectFormats(m.DetectFormats); err != nil {
+			return nil, err
+		}
+	}
+	if err := jsonschema.CheckNumericMode(opts.Numeric); err != nil {
+		return nil, err
+	}
+	m.NumericMode = opts.Numeric
+	m.NumericPadding = opts.Padding
+	m.MultipleOf = opts.MultipleOf
+	m.Percentiles = opts.Percentiles
+	m.Pattern = opts.Pattern
+	err := m.Generate()
+	return m, err
+}
+
 // serviceValidate2JSONBySchema compare 2 json, wether are those jsons same.
 func serviceValidate2JSONBySchema(dataX string, dataY string) (bool, error) {
 	return false, nil
@@ -56,7 +105,7 @@ func serviceUpdateSchema(jsonSchema string, beMegered []byte) (*jsonschema.Schem
 	json.Unmarshal([]byte(jsonSchema), &schema)
 	schemaChan := make(chan *jsonschema.SchemaDocument)
 	go func(jsonData []byte) {
-		res, err := jsonschema.GenerateSchemaDataModel(jsonData, "")
+		res, err := generateMergedSample(&schema, jsonData)
 		if err != nil {
 			fmt.Printf("error %v\n", err)
 			return
@@ -74,6 +123,101 @@ func serviceUpdateSchema(jsonSchema string, beMegered []byte) (*jsonschema.Schem
 	return &schema, nil
 }
 
+// lifecycle states of schema, the schema without state is learning
+const (
+	schemaStateLearning   = "learning"   // the batch job and PATCH merge samples into the schema
+	schemaStateFrozen     = "frozen"     // approved contract, samples are validated against it instead of merged
+	schemaStateDeprecated = "deprecated" // retired contract, samples are neither merged nor validated
+)
+
+func checkSchemaState(state string) error {
+	switch state {
+	case schemaStateLearning, schemaStateFrozen, schemaStateDeprecated:
+		return nil
+	}
+	return fmt.Errorf("unknown state %q, expected learning, frozen or deprecated", state)
+}
+
+// lifecycle the state of schema, learning when it is not set
+func (ss *schemaStore) lifecycle() string {
+	if ss.State == "" {
+		return schemaStateLearning
+	}
+	return ss.State
+}
+
+// schemaViolation a failed keyword of the sample validated against the frozen schema
+type schemaViolation struct {
+	InstanceLocation string `json:"instanceLocation" bson:"instancelocation"`
+	KeywordLocation  string `json:"keywordLocation" bson:"keywordlocation"`
+	Error            string `json:"error" bson:"error"`
+}
+
+// serviceGuardSample validate the json by schema, and returns the failed keywords, none when the json is valid
+func serviceGuardSample(jsonSchema string, data []byte) ([]schemaViolation, error) {
+	schema, err := jsonschema.CompileString("jason-schema", jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+	var sample interface{}
+	if err := json.Unmarshal(data, &sample); err != nil {
+		return nil, err
+	}
+	err = schema.Validate(sample)
+	if err == nil {
+		return nil, nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+	var violations []schemaViolation
+	var leaves func(ve *jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			violations = append(violations, schemaViolation{
+				InstanceLocation: ve.InstanceLocation,
+				KeywordLocation:  ve.KeywordLocation,
+				Error:            ve.Message,
+			})
+		}
+		for _, cause := range ve.Causes {
+			leaves(cause)
+		}
+	}
+	leaves(ve)
+	return violations, nil
+}
+
+// serviceUpdateSchemaWithDrift merge the json into the schema as serviceUpdateSchema, and returns the drift events of the merge.
+// absent is the drift bookkeeping of the schema, it is updated by the merge
+func serviceUpdateSchemaWithDrift(jsonSchema string, absent jsonschema.AbsentSamples, beMegered []byte) (*jsonschema.SchemaDocument, []*jsonschema.DriftEvent, error) {
+	if jsonSchema == "" {
+		return nil, nil, errors.New("empty schema")
+	}
+	var schema jsonschema.SchemaDocument
+	if err := json.Unmarshal([]byte(jsonSchema), &schema); err != nil {
+		return nil, nil, err
+	}
+	res, err := generateMergedSample(&schema, beMegered)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := schema.MergeWithDrift(res.Document, absent, jsonschema.DefaultDriftAbsentSamples)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &schema, events, nil
+}
+
+// generateMergedSample the schema of the json merged into schema, the patterns are learned only when the schema has them
+func generateMergedSample(schema *jsonschema.SchemaDocument, data []byte) (*jsonschema.SchemaDataModel, error) {
+	m := jsonschema.NewSchemaDataModel(data, "")
+	m.Pattern = schema.HasPattern()
+	err := m.Generate()
+	return m, err
+}
+
 // serviceDiff2JSON compare 2 json and return json result
 func serviceDiff2JSON(dataX, dataY string) *comparer.DiffReporter {
 	dx := make(map[string]interface{})
@@ -84,3 +228,131 @@ func serviceDiff2JSON(dataX, dataY string) *comparer.DiffReporter {
 	jsonResult := comparer.GoCmpDiff(dx, dy)
 	return jsonResult
 }
+
+// schemaDiff different item with the learned schema context
+type schemaDiff struct {
+	*comparer.DifferItem
+	Schema  *jsonschema.PathAnnotation `json:"schema"`
+	Failure bool                       `json:"failure"`
+}
+
+// serviceDiff2JSONBySchema compare 2 json, annotate every different item by the schema of key.
+// only the different items that violate the schema are flagged as failure
+func serviceDiff2JSONBySchema(dataX, dataY, key string) ([]*schemaDiff, error) {
+	ss := querySchema(context.Background(), key)
+	if ss == nil {
+		return nil, errors.New("schema not found")
+	}
+	var sd jsonschema.SchemaDocument
+	if err := json.Unmarshal([]byte(ss.Schema), &sd); err != nil {
+		return nil, err
+	}
+
+	dy := make(map[string]interface{})
+	json.Unmarshal([]byte(dataY), &dy)
+	jsonResult := serviceDiff2JSON(dataX, dataY)
+
+	diffs := make([]*schemaDiff, 0, len(jsonResult.Diffs))
+	for _, item := range jsonResult.Diffs {
+		keys := item.JSONPath()
+		value, exist := lookupJSONValue(dy, keys)
+		annotation := sd.Annotate(keys, value, exist)
+		diffs = append(diffs, &schemaDiff{
+			DifferItem: item,
+			Schema:     annotation,
+			Failure:    !annotation.Valid,
+		})
+	}
+	return diffs, nil
+}
+
+// lookupJSONValue get the value of json by path keys
+func lookupJSONValue(data interface{}, keys []string) (interface{}, bool) {
+	cur := data
+	for _, key := range keys {
+		switch vv := cur.(type) {
+		case map[string]interface{}:
+			val, ok := vv[key]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(vv) {
+				return nil, false
+			}
+			cur = vv[index]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// contracts the go types published as json-schema contracts, keyed by contract name
+var contracts = map[string]interface{}{
+	"schema":     schemaStore{},
+	"validation": validation{},
+	"comparing":  comparing{},
+	"difference": schemaDiff{},
+	"testcase":   testcase{},
+}
+
+// contractNames the sorted names of published contracts
+func contractNames() []string {
+	names := make([]string, 0, len(contracts))
+	for name := range contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceContract reflect the go type of contract name to json-schema of the draft, nil when name is unknown
+func serviceContract(name string, draft string) (*jsonschema.SchemaDocument, error) {
+	v, ok := contracts[name]
+	if !ok {
+		return nil, nil
+	}
+	version := 2020
+	if draft != "" {
+		var err error
+		if version, err = jsonschema.DraftVersion(draft); err != nil {
+			return nil, err
+		}
+	}
+	return jsonschema.Reflect(v, version)
+}
+
+// serviceCodegen write the models of the stored json-schema in lang: go, java or ts
+func serviceCodegen(ss *schemaStore, lang string, name string) (string, error) {
+	if err := jsonschema.CheckCodeLang(lang); err != nil {
+		return "", err
+	}
+	var sd jsonschema.SchemaDocument
+	if err := json.Unmarshal([]byte(ss.Schema), &sd); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	if err := sd.WriteCode(&code, lang, name); err != nil {
+		return "", err
+	}
+	return code.String(), nil
+}
+
+// sampleOptions options of sample generation, bind from url query
+type sampleOptions struct {
+	Mode  string `form:"mode"` // valid, boundary or invalid
+	Count int    `form:"count"`
+	Seed  int64  `form:"seed"`
+}
+
+// serviceSamples generate the sample json of the stored json-schema
+func serviceSamples(ss *schemaStore, opts sampleOptions) ([]*jsonschema.Sample, error) {
+	schema, err := jsonschema.CompileString(ss.Key, ss.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Samples(jsonschema.SampleOptions{Mode: opts.Mode, Count: opts.Count, Seed: opts.Seed})
+}
@@ -0,0 +1,180 @@
+package arex
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	dog "github.com/DataDog/zstd"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// harDocument the HTTP Archive exported by browsers and proxies, only the fields of recordings
+type harDocument struct {
+	Log struct {
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	Request         struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding,omitempty"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// servletPath the path and query of the request url, such as /api/orders?page=1
+func (e *harEntry) servletPath() (string, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return "", err
+	}
+	return u.RequestURI(), nil
+}
+
+// headers the request headers, the http/2 pseudo headers and the sensitiveHeaders are not recorded
+func (e *harEntry) headers() map[string]string {
+	headers := make(map[string]string, len(e.Request.Headers))
+	for _, h := range e.Request.Headers {
+		if !strings.HasPrefix(h.Name, ":") && !sensitiveHeaders[strings.ToLower(h.Name)] {
+			headers[h.Name] = h.Value
+		}
+	}
+	return headers
+}
+
+// bodies the request and response bodies, the response text may be base64 encoded
+func (e *harEntry) bodies() (request, response []byte, err error) {
+	if e.Request.PostData != nil {
+		request = []byte(e.Request.PostData.Text)
+	}
+	response = []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		if response, err = base64.StdEncoding.DecodeString(e.Response.Content.Text); err != nil {
+			return nil, nil, fmt.Errorf("the base64 response: %v", err)
+		}
+	}
+	return request, response, nil
+}
+
+// isJSON whether the response of entry is json, the other entries of browsers are documents, scripts and images
+func (e *harEntry) isJSON() bool {
+	return strings.Contains(strings.ToLower(e.Response.Content.MimeType), "json")
+}
+
+// isSuccess whether the response status of entry is 2xx, the error responses are not the contract of the servlet
+func (e *harEntry) isSuccess() bool {
+	return e.Response.Status >= 200 && e.Response.Status < 300
+}
+
+// recordedTime the time the entry started, now when it is not RFC 3339
+func (e *harEntry) recordedTime() time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// harServletmockers convert the json entries of har into the ServletMocker recordings of app,
+// the bodies are encoded as the AREX agent records them. returns the mockers and the count of skipped entries
+func harServletmockers(har *harDocument, appID string) ([]*servletmocker, int) {
+	mockers := make([]*servletmocker, 0, len(har.Log.Entries))
+	skipped := 0
+	for i, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !entry.isJSON() || !entry.isSuccess() {
+			skipped++
+			continue
+		}
+		request, response, err := entry.bodies()
+		if err != nil {
+			fmt.Printf("har entry %d: %v\n", i, err)
+			skipped++
+			continue
+		}
+		mocker := &servletmocker{
+			ID:             bson.NewObjectId().Hex(),
+			AppID:          appID,
+			CreateTime:     entry.recordedTime(),
+			Method:         strings.ToUpper(entry.Request.Method),
+			Path:           u.RequestURI(),
+			RequestHeaders: entry.headers(),
+			Status:         entry.Response.Status,
+		}
+		if len(request) > 0 {
+			if mocker.Request, err = encodeRecordedBody(request); err != nil {
+				fmt.Printf("har entry %d: %v\n", i, err)
+				skipped++
+				continue
+			}
+		}
+		text, err := encodeRecordedBody(response)
+		if err != nil {
+			fmt.Printf("har entry %d: %v\n", i, err)
+			skipped++
+			continue
+		}
+		mocker.Response = []byte(text)
+		mockers = append(mockers, mocker)
+	}
+	return mockers, skipped
+}
+
+// encodeRecordedBody encode the body as the AREX agent records it, base64 of zstd
+func encodeRecordedBody(body []byte) (string, error) {
+	compressed, err := dog.Compress(nil, body)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(compressed), nil
+}
+
+// harImport the result of importing a har file
+type harImport struct {
+	Mockers    int `json:"mockers"`
+	Skipped    int `json:"skipped"`
+	Recordings int `json:"recordings"` // the recordings learned when learn is true
+}
+
+// serviceImportHAR save the json entries of har as the ServletMocker recordings of app,
+// and learn their schemas when learn is true
+func serviceImportHAR(ctx context.Context, har *harDocument, appID string, learn bool) (*harImport, error) {
+	mockers, skipped := harServletmockers(har, appID)
+	if len(mockers) == 0 {
+		return nil, fmt.Errorf("no json entry in %d entries", len(har.Log.Entries))
+	}
+	if err := saveServletmockers(ctx, mockers); err != nil {
+		return nil, err
+	}
+	res := &harImport{Mockers: len(mockers), Skipped: skipped}
+	if learn {
+		count, err := learnRecordings(ctx, recordingList(servletMockerRecordings(mockers)))
+		if err != nil {
+			return nil, err
+		}
+		res.Recordings = count
+	}
+	return res, nil
+}
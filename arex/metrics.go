@@ -0,0 +1,18 @@
+package arex
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// driftEventsTotal counts the drift events found by batch learning, served by /metrics of the default registry
+var driftEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "arex_schema_drift_events_total",
+	Help: "The count of schema drift events found by batch learning.",
+}, []string{"key", "kind"})
+
+// violationsTotal counts the recordings that violate the frozen schemas
+var violationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "arex_schema_violations_total",
+	Help: "The count of recordings that violate the frozen schemas.",
+}, []string{"key"})
@@ -0,0 +1,300 @@
+package arex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arextest/arexAnalysis/jsonschema"
+)
+
+const (
+	openAPIVersion       = "3.1.0"
+	openAPIDialect       = "https://json-schema.org/draft/2020-12/schema"
+	openAPIComponentsRef = "#/components/schemas/"
+)
+
+// openAPIDocument OpenAPI 3.1 description of the learned contracts of an app
+type openAPIDocument struct {
+	OpenAPI           string                                  `json:"openapi"`
+	Info              openAPIInfo                             `json:"info"`
+	JSONSchemaDialect string                                  `json:"jsonSchemaDialect"`
+	Paths             map[string]map[string]*openAPIOperation `json:"paths"`
+	Components        openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]interface{} `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"` // query or header
+	Required bool        `json:"required,omitempty"`
+	Schema   interface{} `json:"schema"`
+}
+
+type openAPIBody struct {
+	Content map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content,omitempty"`
+}
+
+type openAPIMedia struct {
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// openAPIEndpoint the learned schemas of one path, and the methods recorded by ServletMocker
+type openAPIEndpoint struct {
+	Methods map[string]bool
+	Schemas map[string]*jsonschema.SchemaDocument // keyed by the part of getAREXPartKey
+}
+
+// exportOpenAPI assemble the stored schemas of appid into OpenAPI document.
+// the paths and parts are decoded from the schema keys, the methods come from ServletMocker.
+func exportOpenAPI(ctx context.Context, appid string) (*openAPIDocument, error) {
+	schemas := querySchemasByPrefix(ctx, appid+"-")
+	mockers := queryServletmocker(ctx, appid, time.Time{})
+	return buildOpenAPI(appid, schemas, mockers)
+}
+
+func buildOpenAPI(appid string, schemas []*schemaStore, mockers []*servletmocker) (*openAPIDocument, error) {
+	endpoints := make(map[string]*openAPIEndpoint)
+	endpointOf := func(path string) *openAPIEndpoint {
+		if endpoint, ok := endpoints[path]; ok {
+			return endpoint
+		}
+		endpoint := &openAPIEndpoint{Methods: make(map[string]bool), Schemas: make(map[string]*jsonschema.SchemaDocument)}
+		endpoints[path] = endpoint
+		return endpoint
+	}
+
+	for _, ss := range schemas {
+		path, part, ok := parseAREXKey(ss.Key, appid)
+		if !ok {
+			continue
+		}
+		var doc jsonschema.SchemaDocument
+		if err := json.Unmarshal([]byte(ss.Schema), &doc); err != nil {
+			return nil, fmt.Errorf("schema %s: %v", ss.Key, err)
+		}
+		endpointOf(path).Schemas[part] = &doc
+	}
+
+	for _, mocker := range mockers {
+		if mocker.Path == "" {
+			continue
+		}
+		endpoint := endpointOf(servletPath(mocker.Path))
+		if mocker.Method != "" {
+			endpoint.Methods[strings.ToLower(mocker.Method)] = true
+		}
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       appid,
+			Description: "the contracts learned from the recordings of " + appid,
+			Version:     time.Now().Format("2006.01.02"),
+		},
+		JSONSchemaDialect: openAPIDialect,
+		Paths:             make(map[string]map[string]*openAPIOperation),
+		Components:        openAPIComponents{Schemas: make(map[string]interface{})},
+	}
+
+	paths := make([]string, 0, len(endpoints))
+	for path := range endpoints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	// the paths of the same identifier, such as /api/orders and /api-orders, are suffixed
+	used := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		endpoint := endpoints[path]
+		base := pathName(path)
+		name := base
+		for i := 2; used[name]; i++ {
+			name = base + "_" + strconv.Itoa(i)
+		}
+		used[name] = true
+		methods := make([]string, 0, len(endpoint.Methods))
+		for method := range endpoint.Methods {
+			methods = append(methods, method)
+		}
+		if len(methods) == 0 {
+			// no recording tells the method
+			methods = append(methods, "get")
+		}
+		sort.Strings(methods)
+
+		var responseRef, requestRef map[string]interface{}
+		var err error
+		if response := endpoint.Schemas[partResponse]; response != nil {
+			if responseRef, err = doc.addComponent(name+"_response", response); err != nil {
+				return nil, err
+			}
+		}
+		if request := endpoint.Schemas[partRequest]; request != nil {
+			if requestRef, err = doc.addComponent(name+"_request", request); err != nil {
+				return nil, err
+			}
+		}
+		parameters, err := openAPIParameters(endpoint.Schemas[partQuery], "query")
+		if err != nil {
+			return nil, err
+		}
+		headers, err := openAPIParameters(endpoint.Schemas[partHeaders], "header")
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, headers...)
+
+		operations := make(map[string]*openAPIOperation, len(methods))
+		for _, method := range methods {
+			op := &openAPIOperation{
+				OperationID: method + "_" + name,
+				Summary:     strings.ToUpper(method) + " " + path,
+				Parameters:  parameters,
+				Responses:   map[string]*openAPIResponse{"200": {Description: "learned response"}},
+			}
+			if responseRef != nil {
+				op.Responses["200"].Content = map[string]openAPIMedia{"application/json": {Schema: responseRef}}
+			}
+			if requestRef != nil && method != "get" && method != "head" {
+				op.RequestBody = &openAPIBody{Content: map[string]openAPIMedia{"application/json": {Schema: requestRef}}}
+			}
+			operations[method] = op
+		}
+		doc.Paths[path] = operations
+	}
+	return doc, nil
+}
+
+// addComponent put the schema into components, and returns the $ref of it.
+// the $defs of schema are hoisted into components, and the local references are rewritten.
+func (d *openAPIDocument) addComponent(name string, sd *jsonschema.SchemaDocument) (map[string]interface{}, error) {
+	// spell the schema in the dialect of OpenAPI 3.1
+	draft := *sd
+	draft.Schema = openAPIDialect
+	text, err := json.Marshal(&draft)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(text, &schema); err != nil {
+		return nil, err
+	}
+	delete(schema, "$schema")
+
+	defs, _ := schema["$defs"].(map[string]interface{})
+	delete(schema, "$defs")
+	refs := map[string]string{"#": openAPIComponentsRef + name}
+	for defName := range defs {
+		refs["#/$defs/"+defName] = openAPIComponentsRef + name + "_" + defName
+	}
+	for defName, def := range defs {
+		d.Components.Schemas[name+"_"+defName] = rewriteRefs(def, refs)
+	}
+	d.Components.Schemas[name] = rewriteRefs(schema, refs)
+	return map[string]interface{}{"$ref": openAPIComponentsRef + name}, nil
+}
+
+// openAPIParameters the parameters in query or header of the learned object schema, one for each property
+func openAPIParameters(sd *jsonschema.SchemaDocument, in string) ([]*openAPIParameter, error) {
+	if sd == nil {
+		return nil, nil
+	}
+	draft := *sd
+	draft.Schema = openAPIDialect
+	text, err := json.Marshal(&draft)
+	if err != nil {
+		return nil, err
+	}
+	var schema struct {
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(text, &schema); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	parameters := make([]*openAPIParameter, 0, len(names))
+	for _, name := range names {
+		parameters = append(parameters, &openAPIParameter{
+			Name:     name,
+			In:       in,
+			Required: required[name],
+			Schema:   schema.Properties[name],
+		})
+	}
+	return parameters, nil
+}
+
+// rewriteRefs replace the $ref of json-schema value by refs
+func rewriteRefs(v interface{}, refs map[string]string) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for key, value := range vv {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				if target, ok := refs[ref]; ok {
+					vv[key] = target
+				}
+				continue
+			}
+			vv[key] = rewriteRefs(value, refs)
+		}
+	case []interface{}:
+		for i := range vv {
+			vv[i] = rewriteRefs(vv[i], refs)
+		}
+	}
+	return v
+}
+
+// pathName the identifier of path, such as api_orders_id of /api/orders/{id}
+func pathName(path string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, path)
+	name = strings.Trim(name, "_")
+	for strings.Contains(name, "__") {
+		name = strings.ReplaceAll(name, "__", "_")
+	}
+	if name == "" {
+		return "root"
+	}
+	return name
+}
@@ -7,8 +7,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/arextest/arexAnalysis/jsonschema"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // Auth Represents authentication helpers provided by Postman
@@ -181,9 +186,8 @@ type ItemGroup struct {
 	Description interface{} `json:"description,omitempty"`
 	Event       []*Event    `json:"event,omitempty"`
 
-	// Items are entities which contain an actual HTTP request, and sample responses attached to it. Folders may contain many items.
-	// Item []interface{} `json:"item"`
-	Item []Item `json:"item"`
+	// Items are entities which contain an actual HTTP request, and sample responses attached to it. Folders may contain many items and folders.
+	Item []interface{} `json:"item"`
 
 	// A folder's friendly name is defined by this field. You would want to set this field to a value that would allow you to easily identify this folder.
 	Name                    string                   `json:"name,omitempty"`
@@ -245,7 +249,10 @@ type Response struct {
 	Header interface{} `json:"header,omitempty"`
 
 	// A unique, user defined identifier that can  be used to refer to this response from requests.
-	ID              string      `json:"id,omitempty"`
+	ID string `json:"id,omitempty"`
+
+	// The name of the saved example
+	Name            string      `json:"name,omitempty"`
 	OriginalRequest interface{} `json:"originalRequest,omitempty"`
 
 	// The time taken by the request to complete. If a number, the unit is milliseconds. If the response is manually created, this can be set to `null`.
@@ -1431,6 +1438,18 @@ func (strct *ItemGroup) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalJSON the request object, or the url string of GET request
+func (strct *Request) UnmarshalJSON(b []byte) error {
+	var url string
+	if err := json.Unmarshal(b, &url); err == nil {
+		*strct = Request{Method: "GET", URL: url}
+		return nil
+	}
+	// request has the fields of Request without the method UnmarshalJSON
+	type request Request
+	return json.Unmarshal(b, (*request)(strct))
+}
+
 func (strct *Root) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(make([]byte, 0))
 	buf.WriteString("{")
@@ -1562,15 +1581,13 @@ func (strct *Root) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-func exportAREXToPostman(appid, start string) interface{} {
-	var startTime time.Time
-	startTime, err := time.Parse("2022-02-22", start)
-	if err != nil {
-		startTime = time.Time{}
+func exportAREXToPostman(appid string, startTime time.Time) interface{} {
+	ctx := context.TODO()
+	rl := queryServletmocker(ctx, appid, startTime)
+	root := getPostmanRoot(rl, queryMockerSchemas(ctx, rl))
+	if root == nil {
+		return nil
 	}
-
-	rl := queryServletmocker(context.TODO(), appid, startTime)
-	root := getPostmanRoot(rl)
 	jsonData, err := root.MarshalJSON()
 	if err != nil {
 		fmt.Println(err)
@@ -1582,7 +1599,9 @@ func exportAREXToPostman(appid, start string) interface{} {
 	return structData
 }
 
-func getPostmanRoot(mockers []*servletmocker) *Root {
+// getPostmanRoot the collection of the mockers, in folders of app and path. an item has the recorded response
+// as example, and the tests of status and of the learned response schema in schemas by key
+func getPostmanRoot(mockers []*servletmocker, schemas map[string]string) *Root {
 	hset := make(map[string]struct{})
 	filterItem := func(item *Item) bool {
 		var sb strings.Builder
@@ -1606,30 +1625,24 @@ func getPostmanRoot(mockers []*servletmocker) *Root {
 
 	convertMockerToItem := func(mocker *servletmocker) *Item {
 		var item Item
-		item.Name = mocker.AppID + mocker.Path
+		item.Name = mocker.Method + " " + mocker.Path
 		var request Request
 		request.Method = mocker.Method
 		headerlist := make([]Header, 0)
 		for mkey, mvalue := range mocker.RequestHeaders {
 			headerlist = append(headerlist, Header{Key: FirstUpper(mkey), Value: mvalue, Disabled: false})
 		}
+		sort.Slice(headerlist, func(i, j int) bool { return headerlist[i].Key < headerlist[j].Key })
 		request.Header = append(headerlist, Header{Key: "arex-record-id", Value: mocker.ID})
 		request.URL = "http://{{app_arexed_url}}" + mocker.Path
 		request.Body.Mode = "raw"
 		request.Body.Options = "{\"raw\":{\"language\":\"json\"}}"
 		if mocker.Request != "" {
-			bytes, err := unBase64andZstdString(mocker.Request)
+			bytes, err := decodePayload(mocker.Request)
 			if err != nil {
 				request.Body.Raw = err.Error()
 			} else {
-				text := string(bytes)
-				request.Body.Raw = string(text)
-				if strings.Contains(text, "ew0") {
-					unzipData, err := base64.StdEncoding.DecodeString(text)
-					if err == nil {
-						request.Body.Raw = string(unzipData)
-					}
-				}
+				request.Body.Raw = string(bytes)
 			}
 		}
 		item.Request = request
@@ -1638,6 +1651,20 @@ func getPostmanRoot(mockers []*servletmocker) *Root {
 			return nil
 		}
 
+		if len(mocker.Response) > 0 {
+			response := &Response{Name: mocker.ID, Code: mocker.status(), Status: http.StatusText(mocker.status()), OriginalRequest: request}
+			if body, err := decodePayload(string(mocker.Response)); err != nil {
+				response.Body = err.Error()
+			} else {
+				response.Body = string(body)
+				if json.Valid(body) {
+					response.Header = []Header{{Key: "Content-Type", Value: "application/json"}}
+				}
+			}
+			item.Response = []*Response{response}
+		}
+		apiName := base64.URLEncoding.EncodeToString([]byte(servletPath(mocker.Path)))
+		item.Event = []*Event{postmanTestEvent(mocker.status(), schemas[getAREXPartKey(mocker.AppID, apiName, partResponse)])}
 		return &item
 	}
 
@@ -1653,15 +1680,255 @@ func getPostmanRoot(mockers []*servletmocker) *Root {
 	var root Root
 	root.Info = &info
 
-	items := make([]interface{}, 0)
+	// the folders of app and of path, in the order of the first mocker
+	apps := make([]*ItemGroup, 0)
+	appFolders := make(map[string]*ItemGroup)
+	pathFolders := make(map[string]*ItemGroup)
 	for _, mocker := range mockers {
 		item := convertMockerToItem(mocker)
 		if item == nil {
 			continue
 		}
-		items = append(items, item)
+		app, ok := appFolders[mocker.AppID]
+		if !ok {
+			app = &ItemGroup{Name: mocker.AppID, Item: make([]interface{}, 0)}
+			appFolders[mocker.AppID] = app
+			apps = append(apps, app)
+		}
+		path := servletPath(mocker.Path)
+		folder, ok := pathFolders[mocker.AppID+"-"+path]
+		if !ok {
+			folder = &ItemGroup{Name: path, Item: make([]interface{}, 0)}
+			pathFolders[mocker.AppID+"-"+path] = folder
+			app.Item = append(app.Item, folder)
+		}
+		folder.Item = append(folder.Item, item)
 	}
 
+	items := make([]interface{}, 0, len(apps))
+	for _, app := range apps {
+		items = append(items, app)
+	}
 	root.Item = items
 	return &root
 }
+
+// postmanSchemaDraft the meta schema of the schemas validated by ajv of postman sandbox, which knows draft 7 only
+const postmanSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// postmanTestEvent the test script asserting the recorded status, and validating the response against the schema
+// by ajv of postman sandbox. the schema is not validated when it is empty
+func postmanTestEvent(status int, schema string) *Event {
+	exec := []string{
+		fmt.Sprintf("pm.test(\"Status code is %d\", function () {", status),
+		fmt.Sprintf("    pm.response.to.have.status(%d);", status),
+		"});",
+	}
+	if text, ok := postmanSchema(schema); ok {
+		exec = append(exec,
+			"const schema = "+text+";",
+			"pm.test(\"Response matches the learned schema\", function () {",
+			"    const Ajv = require('ajv');",
+			"    const ajv = new Ajv({logger: false, unknownFormats: 'ignore'});",
+			"    const valid = ajv.validate(schema, pm.response.json());",
+			"    pm.expect(valid, ajv.errorsText()).to.be.true;",
+			"});",
+		)
+	}
+	return &Event{Listen: "test", Script: &Script{Type: "text/javascript", Exec: exec}}
+}
+
+// postmanSchema the learned schema spelled by draft 7, such as definitions and the items array of tuple
+func postmanSchema(schema string) (string, bool) {
+	var doc jsonschema.SchemaDocument
+	if schema == "" || json.Unmarshal([]byte(schema), &doc) != nil {
+		return "", false
+	}
+	doc.Schema = postmanSchemaDraft
+	text, err := json.Marshal(&doc)
+	if err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	return string(text), true
+}
+
+// postmanItems the items of the nodes of collection, the item groups are walked recursively
+func postmanItems(nodes []interface{}) ([]*Item, error) {
+	items := make([]*Item, 0)
+	for _, node := range nodes {
+		text, err := json.Marshal(node)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(text, &fields); err != nil {
+			return nil, err
+		}
+		if _, ok := fields["request"]; ok {
+			var item Item
+			if err := item.UnmarshalJSON(text); err != nil {
+				return nil, fmt.Errorf("item %s: %v", fields["name"], err)
+			}
+			items = append(items, &item)
+			continue
+		}
+		var group ItemGroup
+		if err := group.UnmarshalJSON(text); err != nil {
+			return nil, fmt.Errorf("folder %s: %v", fields["name"], err)
+		}
+		groupItems, err := postmanItems(group.Item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, groupItems...)
+	}
+	return items, nil
+}
+
+// postmanPath the path and query of the url of request, the host may be a variable such as {{base_url}}
+func postmanPath(request *Request) string {
+	var raw string
+	switch u := request.URL.(type) {
+	case string:
+		raw = u
+	case map[string]interface{}:
+		if text, ok := u["raw"].(string); ok {
+			raw = text
+		} else if segments, ok := u["path"].([]interface{}); ok {
+			names := make([]string, 0, len(segments))
+			for _, segment := range segments {
+				names = append(names, fmt.Sprint(segment))
+			}
+			raw = "/" + strings.Join(names, "/")
+		}
+	}
+	if i := strings.Index(raw, "://"); i >= 0 {
+		raw = raw[i+3:]
+	}
+	if !strings.HasPrefix(raw, "/") {
+		i := strings.IndexByte(raw, '/')
+		if i < 0 {
+			return ""
+		}
+		raw = raw[i:]
+	}
+	if i := strings.IndexByte(raw, '#'); i >= 0 {
+		raw = raw[:i]
+	}
+	return raw
+}
+
+// postmanServletmocker the ServletMocker recording of the item of app, the response and status are of the first json example,
+// and the recordings of the json bodies of request and examples
+func postmanServletmocker(item *Item, appID string) (*servletmocker, []*Recording, error) {
+	path := postmanPath(&item.Request)
+	if path == "" {
+		return nil, nil, fmt.Errorf("item %s: the url has no path", item.Name)
+	}
+	method, _ := item.Request.Method.(string)
+	if method == "" {
+		method = "GET"
+	}
+	headers := make(map[string]string)
+	for _, h := range item.Request.Header {
+		if !h.Disabled {
+			headers[h.Key] = h.Value
+		}
+	}
+	var request []byte
+	if item.Request.Body.Mode == "raw" && json.Valid([]byte(item.Request.Body.Raw)) {
+		request = []byte(item.Request.Body.Raw)
+	}
+	var responses [][]byte
+	status := 0
+	for _, example := range item.Response {
+		if body, ok := example.Body.(string); ok && json.Valid([]byte(body)) {
+			if len(responses) == 0 {
+				status = example.Code
+			}
+			responses = append(responses, []byte(body))
+		}
+	}
+
+	mocker := &servletmocker{
+		ID:             bson.NewObjectId().Hex(),
+		AppID:          appID,
+		CreateTime:     time.Now(),
+		Method:         strings.ToUpper(method),
+		Path:           path,
+		RequestHeaders: headers,
+		Status:         status,
+	}
+	var err error
+	if len(request) > 0 {
+		if mocker.Request, err = encodeRecordedBody(request); err != nil {
+			return nil, nil, err
+		}
+	}
+	var response []byte
+	if len(responses) > 0 {
+		response = responses[0]
+		text, err := encodeRecordedBody(response)
+		if err != nil {
+			return nil, nil, err
+		}
+		mocker.Response = []byte(text)
+	}
+
+	recordings := servletRecordings(appID, mocker.ID, path, headers, request, response)
+	if len(responses) > 1 {
+		apiName := base64.URLEncoding.EncodeToString([]byte(servletPath(path)))
+		for _, data := range responses[1:] {
+			recordings = append(recordings, &Recording{ID: mocker.ID, Key: getAREXPartKey(appID, apiName, partResponse), Data: data})
+		}
+	}
+	return mocker, recordings, nil
+}
+
+// postmanImport the result of importing a postman collection
+type postmanImport struct {
+	AppID      string   `json:"appid"`
+	Mockers    int      `json:"mockers"`
+	Skipped    []string `json:"skipped,omitempty"` // the items whose url has no path
+	Recordings int      `json:"recordings"`
+}
+
+// serviceImportPostman save the requests of the collection as the ServletMocker recordings of app, and learn the
+// schemas of the request bodies and the example responses. the app is the collection name when it is empty
+func serviceImportPostman(ctx context.Context, root *Root, appID string) (*postmanImport, error) {
+	if appID == "" && root.Info != nil {
+		appID = root.Info.Name
+	}
+	if appID == "" {
+		return nil, errors.New("the app of collection is empty")
+	}
+	items, err := postmanItems(root.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &postmanImport{AppID: appID}
+	mockers := make([]*servletmocker, 0, len(items))
+	recordings := make([]*Recording, 0)
+	for _, item := range items {
+		mocker, itemRecordings, err := postmanServletmocker(item, appID)
+		if err != nil {
+			res.Skipped = append(res.Skipped, err.Error())
+			continue
+		}
+		mockers = append(mockers, mocker)
+		recordings = append(recordings, itemRecordings...)
+	}
+	if len(mockers) == 0 {
+		return nil, fmt.Errorf("no request in %d items", len(items))
+	}
+	if err := saveServletmockers(ctx, mockers); err != nil {
+		return nil, err
+	}
+	res.Mockers = len(mockers)
+	if res.Recordings, err = learnRecordings(ctx, recordingList(recordings)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,270 @@
+package arex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arextest/arexAnalysis/jsonschema"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// status of replayReport and replayCase
+const (
+	replayRunning  = "running"
+	replayFinished = "finished"
+	replayPassed   = "passed"
+	replayFailed   = "failed" // the replay violates the schema learned from the baselines
+	replayError    = "error"  // the pair can not be read or the schema is not learned, or the report can not be saved
+)
+
+// replayConfig where the job reads the baseline/replay pairs, one document of collection is a pair
+type replayConfig struct {
+	mongoCollection `bson:",inline"`
+	// the schema key is the value of KeyField, or getAREXKey of the values of ServiceField and NameField
+	KeyField      string `json:"keyField,omitempty" bson:"keyfield,omitempty"`
+	ServiceField  string `json:"serviceField,omitempty" bson:"servicefield"`
+	NameField     string `json:"nameField,omitempty" bson:"namefield"`
+	BaselineField string `json:"baselineField,omitempty" bson:"baselinefield"`
+	ReplayField   string `json:"replayField,omitempty" bson:"replayfield"`
+	Encoding      string `json:"encoding,omitempty" bson:"encoding"` // the decoder of the fields, auto by default
+}
+
+// replayReport the report of a run of the replay validation job
+type replayReport struct {
+	ID       string       `json:"id" bson:"_id"`
+	Config   replayConfig `json:"config" bson:"config"`
+	Status   string       `json:"status" bson:"status"`
+	Error    string       `json:"error,omitempty" bson:"error,omitempty"`
+	Started  time.Time    `json:"started" bson:"started"`
+	Finished time.Time    `json:"finished,omitempty" bson:"finished,omitempty"`
+	Total    int          `json:"total" bson:"total"`
+	Passed   int          `json:"passed" bson:"passed"`
+	Failed   int          `json:"failed" bson:"failed"`
+	Errors   int          `json:"errors" bson:"errors"`
+	// the cases are saved in their own collection by the report id, a run may have more cases than a document holds
+	Cases []*replayCase `json:"cases,omitempty" bson:"-"`
+}
+
+// replayCase the validation of the replay of a pair against the schema of key
+type replayCase struct {
+	ReportID   string            `json:"-" bson:"reportid"`
+	RecordID   string            `json:"recordid" bson:"recordid"`
+	Key        string            `json:"key,omitempty" bson:"key,omitempty"`
+	Status     string            `json:"status" bson:"status"`
+	Error      string            `json:"error,omitempty" bson:"error,omitempty"`
+	Violations []schemaViolation `json:"violations,omitempty" bson:"violations,omitempty"`
+}
+
+// withDefaults fill the fields of the Ctrip AREX compare results, and check the config
+func (cfg *replayConfig) withDefaults() error {
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	if cfg.KeyField == "" {
+		if cfg.ServiceField == "" {
+			cfg.ServiceField = "service"
+		}
+		if cfg.NameField == "" {
+			cfg.NameField = "resultname"
+		}
+	}
+	if cfg.BaselineField == "" {
+		cfg.BaselineField = "basemsg"
+	}
+	if cfg.ReplayField == "" {
+		cfg.ReplayField = "testmsg"
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = "auto"
+	}
+	return checkRecordingDecoder(cfg.Encoding)
+}
+
+// pair read the schema key, baseline and replay json of the document
+func (cfg *replayConfig) pair(doc map[string]interface{}) (key string, baseline, replay []byte, err error) {
+	text := func(field string) (string, error) {
+		value, ok := doc[field].(string)
+		if !ok || value == "" {
+			return "", fmt.Errorf("field %s is not a string", field)
+		}
+		return value, nil
+	}
+	if cfg.KeyField != "" {
+		if key, err = text(cfg.KeyField); err != nil {
+			return "", nil, nil, err
+		}
+	} else {
+		service, err := text(cfg.ServiceField)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		name, err := text(cfg.NameField)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		key = getAREXKey(service, name)
+	}
+
+	for _, field := range []struct {
+		name string
+		data *[]byte
+	}{{cfg.BaselineField, &baseline}, {cfg.ReplayField, &replay}} {
+		value, err := text(field.name)
+		if err != nil {
+			return key, nil, nil, err
+		}
+		if *field.data, err = recordingDecoders[cfg.Encoding](value); err != nil {
+			return key, nil, nil, fmt.Errorf("field %s: %v", field.name, err)
+		}
+	}
+	return key, baseline, replay, nil
+}
+
+// add the case into the report and count it
+func (r *replayReport) add(c *replayCase) {
+	r.Cases = append(r.Cases, c)
+	r.Total++
+	switch c.Status {
+	case replayPassed:
+		r.Passed++
+	case replayFailed:
+		r.Failed++
+	default:
+		r.Errors++
+	}
+}
+
+// startReplayValidation save the running report of cfg, and run the job in background.
+// cfg is checked by withDefaults before
+func startReplayValidation(cfg replayConfig) (*replayReport, error) {
+	report := &replayReport{
+		ID:      bson.NewObjectId().Hex(),
+		Config:  cfg,
+		Status:  replayRunning,
+		Started: time.Now(),
+	}
+	// the password of uri is not persisted
+	report.Config.URI = cfg.redacted()
+	if err := saveReplayReport(context.Background(), report); err != nil {
+		return nil, err
+	}
+	running := *report
+	go func() {
+		ctx := context.Background()
+		if err := runReplayValidation(ctx, &cfg, report); err != nil {
+			report.Error = err.Error()
+		}
+		report.Status = replayFinished
+		if err := saveReplayCases(ctx, report.ID, report.Cases); err != nil {
+			report.Status, report.Error = replayError, fmt.Sprintf("save cases: %v", err)
+		}
+		report.Finished = time.Now()
+		if err := saveReplayReport(ctx, report); err != nil {
+			// the report is not left running
+			fmt.Printf("save replay report %s failed %v\n", report.ID, err)
+			if err = saveReplayReportError(ctx, report.ID, err); err != nil {
+				fmt.Printf("save replay report %s failed %v\n", report.ID, err)
+			}
+		}
+	}()
+	return &running, nil
+}
+
+// replayBaselineSchema the run-local schema with the baseline merged, the schema is created by the first baseline.
+// the frozen schema is the contract of key, the baselines are not merged into it
+func replayBaselineSchema(schema string, frozen bool, baseline []byte) (string, error) {
+	if frozen {
+		return schema, nil
+	}
+	if !json.Valid(baseline) {
+		return schema, errors.New("the baseline is not json")
+	}
+	if schema == "" {
+		m, err := serviceGenerateSchema(baseline)
+		if err != nil {
+			return "", err
+		}
+		text, err := json.Marshal(m.Document)
+		return string(text), err
+	}
+	var doc jsonschema.SchemaDocument
+	if err := json.Unmarshal([]byte(schema), &doc); err != nil {
+		return schema, err
+	}
+	res, err := generateMergedSample(&doc, baseline)
+	if err != nil {
+		return schema, err
+	}
+	// the baseline conflicting with the schema is not merged, the replays are validated without it
+	if err = doc.MergeSchemaDocument(res.Document); err != nil {
+		return schema, err
+	}
+	text, err := json.Marshal(&doc)
+	if err != nil {
+		return schema, err
+	}
+	return string(text), nil
+}
+
+// runReplayValidation learn the schemas from the baselines of the pairs, then validate the replays against them.
+// the run is read-only: the baselines are merged into the run-local schemas of keys in memory only,
+// the stored schemas, drifts, violations and proposals are not written
+func runReplayValidation(ctx context.Context, cfg *replayConfig, report *replayReport) error {
+	docs, err := cfg.find(ctx)
+	if err != nil {
+		return err
+	}
+
+	type pair struct {
+		c      *replayCase
+		replay []byte
+	}
+	pairs := make([]*pair, 0, len(docs))
+	schemas := make(map[string]string)
+	frozen := make(map[string]bool)
+	for _, doc := range docs {
+		c := &replayCase{RecordID: recordingID(doc["_id"])}
+		key, baseline, replay, err := cfg.pair(doc)
+		c.Key = key
+		if err != nil {
+			c.Status, c.Error = replayError, err.Error()
+			report.add(c)
+			continue
+		}
+		if _, ok := schemas[key]; !ok {
+			schemas[key] = ""
+			if ss := querySchema(ctx, key); ss != nil {
+				schemas[key], frozen[key] = ss.Schema, ss.lifecycle() == schemaStateFrozen
+			}
+		}
+		if schemas[key], err = replayBaselineSchema(schemas[key], frozen[key], baseline); err != nil {
+			c.Status, c.Error = replayError, fmt.Sprintf("baseline: %v", err)
+			report.add(c)
+			continue
+		}
+		pairs = append(pairs, &pair{c: c, replay: replay})
+	}
+
+	for _, p := range pairs {
+		schema := schemas[p.c.Key]
+		if schema == "" {
+			p.c.Status, p.c.Error = replayError, "schema not found"
+			report.add(p.c)
+			continue
+		}
+		violations, err := serviceGuardSample(schema, p.replay)
+		switch {
+		case err != nil:
+			p.c.Status, p.c.Error = replayError, err.Error()
+		case len(violations) > 0:
+			p.c.Status, p.c.Violations = replayFailed, violations
+		default:
+			p.c.Status = replayPassed
+		}
+		report.add(p.c)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package arex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arextest/arexAnalysis/jsonschema"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// status of schemaProposal
+const (
+	proposalPending  = "pending"
+	proposalApproved = "approved"
+	proposalRejected = "rejected"
+)
+
+// actions of proposalReview
+const (
+	reviewApprove = "approve"
+	reviewReject  = "reject"
+	reviewEdit    = "edit"
+)
+
+var (
+	errProposalNotFound  = errors.New("proposal not found")
+	errProposalClosed    = errors.New("proposal is not pending")
+	errSchemaNotLearning = errors.New("schema is not learning, the proposal can not be approved")
+	errProposalConflict  = errors.New("proposal is changed by another merge or review")
+)
+
+// proposalMergeRetries the merges of a sample retried on errProposalConflict
+const proposalMergeRetries = 3
+
+// sampleKeywords the annotations collected from the samples, they are not validated so their changes are not proposed
+var sampleKeywords = []string{"examples", jsonschema.PercentilesKeyword, jsonschema.AbsentSamplesKeyword}
+
+// schemaProposal the proposed revision of the schema of key, the merges of the batch job and PATCH
+// are folded into the pending proposal of key until it is approved or rejected
+type schemaProposal struct {
+	ID      string                   `json:"id" bson:"_id"`
+	Key     string                   `json:"key" bson:"key"`
+	Status  string                   `json:"status" bson:"status"`
+	Schema  string                   `json:"schema" bson:"schema"`
+	Diffs   []*proposalDiff          `json:"diffs" bson:"diffs"` // against the current schema of key
+	Samples int                      `json:"samples" bson:"samples"`
+	Version int                      `json:"version" bson:"version"`    // the saves of the proposal, the save of a stale version fails
+	Absent  jsonschema.AbsentSamples `json:"-" bson:"absent,omitempty"` // the drift bookkeeping of the proposed schema
+	Reviews []*proposalReview        `json:"reviews,omitempty" bson:"reviews,omitempty"`
+	Created time.Time                `json:"created" bson:"created"`
+	Updated time.Time                `json:"updated" bson:"updated"`
+}
+
+// proposalDiff a changed keyword of the proposed schema, the values are json
+type proposalDiff struct {
+	Path     string `json:"path" bson:"path"` // json pointer in the schema
+	Current  string `json:"current,omitempty" bson:"current,omitempty"`
+	Proposed string `json:"proposed,omitempty" bson:"proposed,omitempty"`
+}
+
+// proposalReview an approval, rejection or edit of the proposal
+type proposalReview struct {
+	Action   string          `json:"action" bson:"action"`
+	Reviewer string          `json:"reviewer" bson:"reviewer" binding:"required"`
+	Comment  string          `json:"comment,omitempty" bson:"comment,omitempty"`
+	Schema   json.RawMessage `json:"schema,omitempty" bson:"-"` // the edited schema
+	Time     time.Time       `json:"time" bson:"time"`
+}
+
+// serviceProposeMerge merge the json into the pending proposal of schema, the proposal is created by the first merge
+// that changes the schema. the merge that changes nothing but the annotations of the samples is saved into the schema.
+// the merge is retried against the latest proposal when another merge or review saves the proposal concurrently.
+// returns the pending proposal, nil when the schema is saved, and the drift events of the merge
+func serviceProposeMerge(ctx context.Context, ss *schemaStore, data []byte) (*schemaProposal, []*jsonschema.DriftEvent, error) {
+	for i := 0; ; i++ {
+		proposal, events, err := proposeMerge(ctx, ss, data)
+		if !errors.Is(err, errProposalConflict) || i == proposalMergeRetries {
+			return proposal, events, err
+		}
+	}
+}
+
+func proposeMerge(ctx context.Context, ss *schemaStore, data []byte) (*schemaProposal, []*jsonschema.DriftEvent, error) {
+	proposal := queryPendingProposal(ctx, ss.Key)
+	base, absent := ss.Schema, ss.Absent
+	if proposal != nil {
+		base, absent = proposal.Schema, proposal.Absent
+	}
+	// the absent counts are not changed in place, the merge may be retried from them
+	counts := make(jsonschema.AbsentSamples, len(absent))
+	for pointer, count := range absent {
+		counts[pointer] = count
+	}
+	merged, events, err := serviceUpdateSchemaWithDrift(base, counts, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	diffs, err := serviceSchemaDiff(ss.Schema, string(text))
+	if err != nil {
+		return nil, nil, err
+	}
+	if proposal == nil && len(diffs) == 0 {
+		ss.Schema = string(text)
+		ss.Absent = counts
+		saveSchema(ctx, *ss)
+		return nil, events, nil
+	}
+
+	now := time.Now()
+	if proposal == nil {
+		proposal = &schemaProposal{ID: bson.NewObjectId().Hex(), Key: ss.Key, Status: proposalPending, Created: now}
+	}
+	proposal.Schema = string(text)
+	proposal.Absent = counts
+	proposal.Diffs = diffs
+	proposal.Samples++
+	proposal.Updated = now
+	if err = saveProposal(ctx, proposal); err != nil {
+		return nil, nil, err
+	}
+	return proposal, events, nil
+}
+
+// serviceReviewProposal approve, reject or edit the pending proposal of id.
+// the approved schema replaces the schema of key, the edited schema replaces the proposed one.
+// only the proposal of the learning schema is approved, the frozen and deprecated schemas are not changed by proposals
+func serviceReviewProposal(ctx context.Context, id string, review *proposalReview) (*schemaProposal, error) {
+	proposal := queryProposal(ctx, id)
+	if proposal == nil {
+		return nil, errProposalNotFound
+	}
+	current := ""
+	if ss := querySchema(ctx, proposal.Key); ss != nil {
+		if review.Action == reviewApprove && ss.lifecycle() != schemaStateLearning {
+			return nil, errSchemaNotLearning
+		}
+		current = ss.Schema
+	}
+	if err := proposal.apply(review, current); err != nil {
+		return nil, err
+	}
+	// the proposal is saved first, the schema is not approved from a proposal changed concurrently
+	if err := saveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	if proposal.Status == proposalApproved {
+		saveSchema(ctx, schemaStore{Key: proposal.Key, Schema: proposal.Schema, Absent: proposal.Absent})
+	}
+	return proposal, nil
+}
+
+// serviceCloseProposal reject the pending proposal of key when the schema leaves learning state,
+// the samples merged into it are not approved into the frozen or deprecated schema
+func serviceCloseProposal(ctx context.Context, key, state string) error {
+	if state == schemaStateLearning {
+		return nil
+	}
+	proposal := queryPendingProposal(ctx, key)
+	if proposal == nil {
+		return nil
+	}
+	review := &proposalReview{Action: reviewReject, Reviewer: "arex", Comment: "the schema is " + state}
+	if err := proposal.apply(review, ""); err != nil {
+		return err
+	}
+	return saveProposal(ctx, proposal)
+}
+
+// apply the review to the pending proposal, current is the schema of key that the diffs are against
+func (p *schemaProposal) apply(review *proposalReview, current string) error {
+	if p.Status != proposalPending {
+		return errProposalClosed
+	}
+	switch review.Action {
+	case reviewApprove:
+		p.Status = proposalApproved
+	case reviewReject:
+		p.Status = proposalRejected
+	case reviewEdit:
+		if len(review.Schema) == 0 {
+			return errors.New("the edited schema is empty")
+		}
+		if _, err := jsonschema.CompileString("jason-schema", string(review.Schema)); err != nil {
+			return fmt.Errorf("the edited schema: %v", err)
+		}
+		diffs, err := serviceSchemaDiff(current, string(review.Schema))
+		if err != nil {
+			return err
+		}
+		p.Schema = string(review.Schema)
+		p.Diffs = diffs
+	default:
+		return fmt.Errorf("unknown action %q, expected approve, reject or edit", review.Action)
+	}
+	review.Time = time.Now()
+	p.Reviews = append(p.Reviews, review)
+	p.Updated = review.Time
+	return nil
+}
+
+// serviceSchemaDiff the changed keywords from the current schema to the proposed one,
+// the annotations of the samples, such as examples and percentiles, are ignored
+func serviceSchemaDiff(current, proposed string) ([]*proposalDiff, error) {
+	var cx, py interface{}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &cx); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(proposed), &py); err != nil {
+		return nil, err
+	}
+	cx = withoutKeywords(cx, sampleKeywords)
+	py = withoutKeywords(py, sampleKeywords)
+
+	diffs := make([]*proposalDiff, 0)
+	diffJSON(cx, py, nil, func(keys []string, x, y interface{}) {
+		diff := &proposalDiff{Path: jsonPointer(keys)}
+		if x != nil {
+			text, _ := json.Marshal(x)
+			diff.Current = string(text)
+		}
+		if y != nil {
+			text, _ := json.Marshal(y)
+			diff.Proposed = string(text)
+		}
+		diffs = append(diffs, diff)
+	})
+	return diffs, nil
+}
+
+// diffJSON report the different values of x and y, objects are compared by keys and arrays of the same length by items
+func diffJSON(x, y interface{}, keys []string, report func(keys []string, x, y interface{})) {
+	mx, okx := x.(map[string]interface{})
+	my, oky := y.(map[string]interface{})
+	if okx && oky {
+		names := make([]string, 0, len(mx)+len(my))
+		for name := range mx {
+			names = append(names, name)
+		}
+		for name := range my {
+			if _, ok := mx[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			diffJSON(mx[name], my[name], append(keys[:len(keys):len(keys)], name), report)
+		}
+		return
+	}
+	ax, okx := x.([]interface{})
+	ay, oky := y.([]interface{})
+	if okx && oky && len(ax) == len(ay) {
+		for i := range ax {
+			diffJSON(ax[i], ay[i], append(keys[:len(keys):len(keys)], strconv.Itoa(i)), report)
+		}
+		return
+	}
+	if !reflect.DeepEqual(x, y) {
+		report(keys, x, y)
+	}
+}
+
+// schemaMapKeywords the keywords whose values are maps of names to schemas, the names are not keywords
+var schemaMapKeywords = map[string]bool{
+	"properties": true, "patternProperties": true, "dependentSchemas": true, "$defs": true, "definitions": true,
+}
+
+// withoutKeywords remove the keywords of names from the json schema in place
+func withoutKeywords(v interface{}, names []string) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, name := range names {
+			delete(vv, name)
+		}
+		for keyword, value := range vv {
+			schemas, ok := value.(map[string]interface{})
+			if !schemaMapKeywords[keyword] || !ok {
+				withoutKeywords(value, names)
+				continue
+			}
+			for _, schema := range schemas {
+				withoutKeywords(schema, names)
+			}
+		}
+	case []interface{}:
+		for _, value := range vv {
+			withoutKeywords(value, names)
+		}
+	}
+	return v
+}
+
+func jsonPointer(keys []string) string {
+	var sb strings.Builder
+	replacer := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, key := range keys {
+		sb.WriteString("/")
+		sb.WriteString(replacer.Replace(key))
+	}
+	return sb.String()
+}
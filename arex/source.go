@@ -0,0 +1,479 @@
+package arex
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	driverbson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Recording a recorded json that the learner merges into the schema of key
+type Recording struct {
+	ID   string // the recording that caused the drift events
+	Key  string
+	Data []byte
+}
+
+// RecordingSource reads the recordings that the learner learns from,
+// such as the ServletMocker of AREX, a mongo collection or a local file
+type RecordingSource interface {
+	Recordings(ctx context.Context) ([]*Recording, error)
+}
+
+// learnRecordings learn the recordings of source into the schemas of their keys, and returns the count of recordings
+func learnRecordings(ctx context.Context, source RecordingSource) (int, error) {
+	recordings, err := source.Recordings(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range recordings {
+		spiderAREXKeySchemaData(ctx, r.Key, r.ID, string(r.Data))
+	}
+	return len(recordings), nil
+}
+
+// recordingDecoders decode the text of recorded field to json, by the name of decoder
+var recordingDecoders = map[string]func(string) ([]byte, error){
+	"auto":   decodePayload, // the codecs sniffed by payloadCodecs
+	"none":   func(in string) ([]byte, error) { return []byte(in), nil },
+	"base64": base64.StdEncoding.DecodeString,
+	"gzip":   unzipBase64andGzipString, // base64 of gzip
+	"zstd":   unBase64andZstdString,    // base64 of zstd
+}
+
+func checkRecordingDecoder(name string) error {
+	if _, ok := recordingDecoders[name]; !ok {
+		return fmt.Errorf("unknown decoder %q, expected auto, none, base64, gzip or zstd", name)
+	}
+	return nil
+}
+
+// fieldMapping where the fields of recording are in a document, the names are dot separated paths such as request.body
+type fieldMapping struct {
+	IDField      string `json:"idField,omitempty"`
+	KeyField     string `json:"keyField,omitempty"` // the schema key, or getAREXKey of the values of ServiceField and NameField
+	ServiceField string `json:"serviceField,omitempty"`
+	NameField    string `json:"nameField,omitempty"`
+	BodyField    string `json:"bodyField"`
+	Decoder      string `json:"decoder,omitempty"` // the decoder of the text body, auto by default
+}
+
+func (m *fieldMapping) withDefaults() error {
+	if m.IDField == "" {
+		m.IDField = "_id"
+	}
+	if m.KeyField == "" && (m.ServiceField == "" || m.NameField == "") {
+		return errors.New("keyField, or serviceField and nameField are required")
+	}
+	if m.BodyField == "" {
+		return errors.New("bodyField is required")
+	}
+	if m.Decoder == "" {
+		m.Decoder = "auto"
+	}
+	return checkRecordingDecoder(m.Decoder)
+}
+
+// recording read the recording of the document by the mapping, the body is a json value or the text of decoder
+func (m *fieldMapping) recording(doc map[string]interface{}) (*Recording, error) {
+	text := func(field string) (string, error) {
+		value, ok := fieldValue(doc, field).(string)
+		if !ok || value == "" {
+			return "", fmt.Errorf("field %s is not a string", field)
+		}
+		return value, nil
+	}
+	r := &Recording{ID: recordingID(fieldValue(doc, m.IDField))}
+	if m.KeyField != "" {
+		key, err := text(m.KeyField)
+		if err != nil {
+			return nil, err
+		}
+		r.Key = key
+	} else {
+		service, err := text(m.ServiceField)
+		if err != nil {
+			return nil, err
+		}
+		name, err := text(m.NameField)
+		if err != nil {
+			return nil, err
+		}
+		r.Key = getAREXKey(service, name)
+	}
+
+	switch body := fieldValue(doc, m.BodyField).(type) {
+	case nil:
+		return nil, fmt.Errorf("field %s is not found", m.BodyField)
+	case string:
+		data, err := recordingDecoders[m.Decoder](body)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", m.BodyField, err)
+		}
+		r.Data = data
+	default:
+		r.Data, _ = json.Marshal(body)
+	}
+	if !json.Valid(r.Data) {
+		return nil, fmt.Errorf("field %s is not json", m.BodyField)
+	}
+	return r, nil
+}
+
+// fieldValue the value of the dot separated path in the json object
+func fieldValue(doc map[string]interface{}, path string) interface{} {
+	var cur interface{} = doc
+	for _, name := range strings.Split(path, ".") {
+		object, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = object[name]
+	}
+	return cur
+}
+
+// recordingID the text of id, the object id of extended json is the hex
+func recordingID(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}:
+		if oid, ok := v["$oid"].(string); ok {
+			return oid
+		}
+	}
+	text, _ := json.Marshal(id)
+	return string(text)
+}
+
+// mongoCollection the documents of a collection, the analysis database when uri is empty
+type mongoCollection struct {
+	URI        string                 `json:"uri,omitempty" bson:"uri,omitempty"`
+	Database   string                 `json:"database,omitempty" bson:"database,omitempty"`
+	Collection string                 `json:"collection" bson:"collection"`
+	Filter     map[string]interface{} `json:"filter,omitempty" bson:"-"` // the operators of filter can not be field names of report
+	Limit      int64                  `json:"limit,omitempty" bson:"limit"`
+}
+
+const (
+	defaultCollectionLimit = 1000
+	maxCollectionLimit     = 10000
+)
+
+// check the collection, the limit is defaultCollectionLimit when it is not set
+func (mc *mongoCollection) check() error {
+	if mc.Collection == "" {
+		return errors.New("collection is required")
+	}
+	if mc.URI != "" && mc.Database == "" {
+		return errors.New("database is required with uri")
+	}
+	if mc.Limit <= 0 {
+		mc.Limit = defaultCollectionLimit
+	}
+	if mc.Limit > maxCollectionLimit {
+		return fmt.Errorf("limit is at most %d", maxCollectionLimit)
+	}
+	return nil
+}
+
+// redacted the uri without password
+func (mc *mongoCollection) redacted() string {
+	if u, err := url.Parse(mc.URI); err == nil && mc.URI != "" {
+		return u.Redacted()
+	}
+	return mc.URI
+}
+
+// find the documents of filter as the objects of relaxed extended json
+func (mc *mongoCollection) find(ctx context.Context) ([]map[string]interface{}, error) {
+	collection, disconnect, err := mc.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer disconnect()
+
+	filter := driverbson.M{}
+	for name, value := range mc.Filter {
+		filter[name] = value
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(mc.Limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := make([]map[string]interface{}, 0)
+	for cursor.Next(ctx) {
+		text, err := driverbson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(text, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cursor.Err()
+}
+
+func (mc *mongoCollection) connect(ctx context.Context) (*mongo.Collection, func(), error) {
+	if mc.URI == "" {
+		return ConnectOfMongoDB().Collection(mc.Collection), func() {}, nil
+	}
+	clientOptions := &options.ClientOptions{}
+	clientOptions.ApplyURI(mc.URI)
+	clientOptions.SetDirect(true)
+	clientOptions.SetMaxPoolSize(100)
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	disconnect := func() {
+		client.Disconnect(context.Background())
+	}
+	return client.Database(mc.Database).Collection(mc.Collection), disconnect, nil
+}
+
+// sources of sourceConfig
+const (
+	sourceServlet = "servlet"
+	sourceMongo   = "mongo"
+	sourceFile    = "file"
+)
+
+// sourceConfig the config of RecordingSource by type, the fields of the other types are ignored
+type sourceConfig struct {
+	Type string `json:"type" binding:"required"`
+	// servlet: the ServletMocker of app since the time
+	AppID string    `json:"appid,omitempty"` // the app of har file too
+	Since time.Time `json:"since,omitempty"`
+	// file: the path in recordingFileDir, ndjson or har
+	Path   string `json:"path,omitempty"`
+	Format string `json:"format,omitempty"`
+	// mongo: the collection, mongo and ndjson: the fields of recording
+	mongoCollection
+	fieldMapping
+}
+
+// source check the config and returns the RecordingSource of type
+func (cfg *sourceConfig) source() (RecordingSource, error) {
+	switch cfg.Type {
+	case sourceServlet:
+		return &servletSource{AppID: cfg.AppID, Since: cfg.Since}, nil
+	case sourceMongo:
+		s := &mongoSource{mongoCollection: cfg.mongoCollection, fieldMapping: cfg.fieldMapping}
+		if err := s.check(); err != nil {
+			return nil, err
+		}
+		if err := s.withDefaults(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case sourceFile:
+		s := &fileSource{Path: cfg.Path, Format: cfg.Format, AppID: cfg.AppID, fieldMapping: cfg.fieldMapping}
+		if err := s.check(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown type %q, expected servlet, mongo or file", cfg.Type)
+}
+
+// mongoSource the recordings of a mongo collection, one document is a recording
+type mongoSource struct {
+	mongoCollection
+	fieldMapping
+}
+
+func (s *mongoSource) Recordings(ctx context.Context) ([]*Recording, error) {
+	docs, err := s.find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mappedRecordings(docs, &s.fieldMapping), nil
+}
+
+// servletSource the recordings of ServletMocker of app since the time, all apps when appid is empty
+type servletSource struct {
+	AppID string
+	Since time.Time
+}
+
+func (s *servletSource) Recordings(ctx context.Context) ([]*Recording, error) {
+	mockers := queryServletmocker(ctx, s.AppID, s.Since)
+	migrateLegacyResponseKeys(ctx, mockers)
+	return servletMockerRecordings(mockers), nil
+}
+
+// servletMockerRecordings the recordings of the ServletMocker recordings, whose bodies are decoded by payloadCodecs
+func servletMockerRecordings(mockers []*servletmocker) []*Recording {
+	var recordings []*Recording
+	for _, m := range mockers {
+		if m.AppID == "" || m.Path == "" {
+			continue
+		}
+		response, err := decodePayload(string(m.Response))
+		if err != nil {
+			continue
+		}
+		var request []byte
+		if m.Request != "" {
+			request, _ = decodePayload(m.Request)
+		}
+		recordings = append(recordings, servletRecordings(m.AppID, m.ID, m.Path, m.RequestHeaders, request, response)...)
+	}
+	return recordings
+}
+
+// recordingList the recordings in memory
+type recordingList []*Recording
+
+func (l recordingList) Recordings(ctx context.Context) ([]*Recording, error) {
+	return l, nil
+}
+
+// servletRecordings the recordings of the response, request body, headers and query string of the recorded path,
+// keyed by getAREXPartKey. the bodies that are not json are not learned
+func servletRecordings(appID, id, path string, headers map[string]string, request, response []byte) []*Recording {
+	apiName := base64.URLEncoding.EncodeToString([]byte(servletPath(path)))
+	var recordings []*Recording
+	add := func(part string, data []byte) {
+		if len(data) > 0 && json.Valid(data) {
+			recordings = append(recordings, &Recording{ID: id, Key: getAREXPartKey(appID, apiName, part), Data: data})
+		}
+	}
+	add(partRequest, request)
+	if len(headers) > 0 {
+		add(partHeaders, servletHeadersJSON(headers))
+	}
+	add(partQuery, servletQueryJSON(path))
+	add(partResponse, response)
+	return recordings
+}
+
+// formats of fileSource
+const (
+	fileFormatNDJSON = "ndjson"
+	fileFormatHAR    = "har"
+)
+
+// recordingFileDir the directory of the files of fileSource
+var recordingFileDir = "recordings"
+
+// fileSource the recordings of a local file in recordingFileDir.
+// a line of ndjson is a recording read by the mapping, an entry of har is a recording of the servlet of app
+type fileSource struct {
+	Path   string
+	Format string
+	AppID  string // the app of har entries
+	fieldMapping
+}
+
+func (s *fileSource) check() error {
+	if s.Path == "" || filepath.IsAbs(s.Path) || strings.HasPrefix(filepath.Clean(s.Path), "..") {
+		return fmt.Errorf("path %q is not a relative path in %s", s.Path, recordingFileDir)
+	}
+	switch s.Format {
+	case fileFormatNDJSON:
+		return s.fieldMapping.withDefaults()
+	case fileFormatHAR:
+		if s.AppID == "" {
+			return errors.New("appid of har is required")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, expected ndjson or har", s.Format)
+}
+
+func (s *fileSource) Recordings(ctx context.Context) ([]*Recording, error) {
+	f, err := os.Open(filepath.Join(recordingFileDir, filepath.Clean(s.Path)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if s.Format == fileFormatHAR {
+		var har harDocument
+		if err := json.NewDecoder(f).Decode(&har); err != nil {
+			return nil, err
+		}
+		return harRecordings(&har, s.AppID, filepath.Base(s.Path)), nil
+	}
+
+	var docs []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &doc); err != nil {
+			fmt.Printf("%s line %d: %v\n", s.Path, line, err)
+			continue
+		}
+		if _, ok := doc[s.IDField]; !ok {
+			doc[s.IDField] = fmt.Sprintf("%s#%d", filepath.Base(s.Path), line)
+		}
+		docs = append(docs, doc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mappedRecordings(docs, &s.fieldMapping), nil
+}
+
+// mappedRecordings the recordings of the documents, the documents that the mapping can not read are skipped
+func mappedRecordings(docs []map[string]interface{}, m *fieldMapping) []*Recording {
+	recordings := make([]*Recording, 0, len(docs))
+	for _, doc := range docs {
+		r, err := m.recording(doc)
+		if err != nil {
+			fmt.Printf("recording %s: %v\n", recordingID(fieldValue(doc, m.IDField)), err)
+			continue
+		}
+		recordings = append(recordings, r)
+	}
+	return recordings
+}
+
+// harRecordings the recordings of the har entries as the servlet recordings of app, the ids are name#index
+func harRecordings(har *harDocument, appID, name string) []*Recording {
+	var recordings []*Recording
+	for i, entry := range har.Log.Entries {
+		if !entry.isSuccess() {
+			continue
+		}
+		path, err := entry.servletPath()
+		if err != nil {
+			fmt.Printf("%s entry %d: %v\n", name, i, err)
+			continue
+		}
+		request, response, err := entry.bodies()
+		if err != nil {
+			fmt.Printf("%s entry %d: %v\n", name, i, err)
+			continue
+		}
+		id := fmt.Sprintf("%s#%d", name, i)
+		recordings = append(recordings, servletRecordings(appID, id, path, entry.headers(), request, response)...)
+	}
+	return recordings
+}
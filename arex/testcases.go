@@ -3,10 +3,18 @@ package arex
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Title, describes what the test intends to verify and includes an identification number;
@@ -32,38 +40,172 @@ type testcase struct {
 	PreScript     string                 `json:"pre-script,omitempty"`
 	Tests         string                 `json:"tests,omitempty"`
 	Settings      []string               `json:"settings,omitempty"`
+
+	// the expected response, the body is validated against Schema when it is learned, or compared with Response
+	Status   int    `json:"status,omitempty"`
+	Response string `json:"response,omitempty"`
+	Schema   string `json:"schema,omitempty"`
 }
 
-func (t *testcase) ToCaseText() string {
-	tmpl, err := template.ParseFiles("../template/golang.tmpl")
+// parseStartDate the date of ?start=, such as 2022-02-22 or 2022-2-22. the zero time when start is empty
+func parseStartDate(start string) (time.Time, error) {
+	if start == "" {
+		return time.Time{}, nil
+	}
+	startTime, err := time.Parse("2006-1-2", start)
 	if err != nil {
-		fmt.Println(err)
-		return ""
+		return time.Time{}, fmt.Errorf("invalid start date %q, expected such as 2022-02-22", start)
 	}
-	var buf bytes.Buffer
-	tmpl.Execute(&buf, t)
-	return buf.String()
+	return startTime, nil
+}
+
+func getTestCases(appid string, startTime time.Time) []*testcase {
+	ctx := context.TODO()
+	rl := queryServletmocker(ctx, appid, startTime)
+	return servletTestCases(rl, queryMockerSchemas(ctx, rl))
+}
+
+// queryMockerSchemas the learned schemas of the apps of mockers by key
+func queryMockerSchemas(ctx context.Context, mockers []*servletmocker) map[string]string {
+	schemas := make(map[string]string)
+	apps := make(map[string]struct{})
+	for _, mocker := range mockers {
+		if _, ok := apps[mocker.AppID]; ok {
+			continue
+		}
+		apps[mocker.AppID] = struct{}{}
+		for _, ss := range querySchemasByPrefix(ctx, mocker.AppID+"-") {
+			schemas[ss.Key] = ss.Schema
+		}
+	}
+	return schemas
+}
+
+// replayUnsafeHeaders the recorded headers that are set by the client of replay
+var replayUnsafeHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"accept-encoding":   true,
+	"connection":        true,
+	"transfer-encoding": true,
 }
 
-func getTestCases(appid, start string) []*testcase {
+// servletTestCases the testcases of the mockers, the expected response is the recorded status, and the
+// learned response schema in schemas by key, or the recorded response when the schema is not learned
+func servletTestCases(mockers []*servletmocker, schemas map[string]string) []*testcase {
+	// the emitted titles, a suffixed title may be the title of another path, such as /api/orders/2
+	titles := make(map[string]bool)
 	convertSevletToTestCase := func(s *servletmocker) *testcase {
 		var tc testcase
-		tc.Title = s.AppID + "_" + strings.ReplaceAll(s.Path, "/", "_")
-		tc.Description = s.ID + ":" + s.CreateTime.GoString()
-		tc.Service = s.Method + "_" + strings.ReplaceAll(s.Path, "/", "_")
+		path := servletPath(s.Path)
+		title := goIdentifier(s.AppID + "_" + path)
+		tc.Title = title
+		for i := 2; titles[tc.Title]; i++ {
+			tc.Title = title + "_" + strconv.Itoa(i)
+		}
+		titles[tc.Title] = true
+		tc.Description = "replays the recording " + s.ID + " of " + s.CreateTime.Format(time.RFC3339)
+		tc.Service = s.AppID
+		tc.InterfaceName = path
+		tc.Protocol = "http"
+		tc.Methods = s.Method
+		tc.URI = s.Path
+		tc.Headers = make(map[string]interface{}, len(s.RequestHeaders))
+		for name, value := range s.RequestHeaders {
+			if !replayUnsafeHeaders[strings.ToLower(name)] {
+				tc.Headers[name] = value
+			}
+		}
+		if s.Request != "" {
+			if body, err := decodePayload(s.Request); err == nil {
+				tc.Body = string(body)
+			}
+		}
+		if tc.Body != "" {
+			tc.BodyFormat = "text"
+			if json.Valid([]byte(tc.Body)) {
+				tc.BodyFormat = "json"
+			}
+		}
+		tc.Status = s.status()
+		if response, err := decodePayload(string(s.Response)); err == nil && json.Valid(response) {
+			tc.Response = string(response)
+		}
+		apiName := base64.URLEncoding.EncodeToString([]byte(path))
+		tc.Schema = schemas[getAREXPartKey(s.AppID, apiName, partResponse)]
 		return &tc
 	}
-	var startTime time.Time
-	startTime, err := time.Parse("2022-02-22", start)
+
+	tcs := make([]*testcase, 0, len(mockers))
+	for _, oneSevlet := range mockers {
+		tcs = append(tcs, convertSevletToTestCase(oneSevlet))
+	}
+	return tcs
+}
+
+// templateDirs the directories of the templates, the working directory of server and of tests
+var templateDirs = []string{"template", "../template"}
+
+func parseTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
+	for _, dir := range templateDirs {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); err == nil {
+			return template.New(name).Funcs(funcs).ParseFiles(file)
+		}
+	}
+	return nil, fmt.Errorf("template %s is not found in %v", name, templateDirs)
+}
+
+// golangTestFile render the testcases of app as a _test.go file of package, which replays them to baseURL
+// or to the http.Handler assigned to arexHandler
+func golangTestFile(appid, pkg, baseURL string, cases []*testcase) ([]byte, error) {
+	funcs := template.FuncMap{
+		"quote": func(v interface{}) string { return strconv.Quote(fmt.Sprint(v)) },
+		// text the raw string literal of s when it can be, or the quoted one
+		"text": func(s string) string {
+			if strings.ContainsAny(s, "`\r") || !utf8.ValidString(s) {
+				return strconv.Quote(s)
+			}
+			return "`" + s + "`"
+		},
+	}
+	tmpl, err := parseTemplate("golang.tmpl", funcs)
 	if err != nil {
-		startTime = time.Time{}
+		return nil, err
+	}
+	if pkg == "" {
+		pkg = strings.ToLower(goIdentifier(appid))
+	}
+	data := struct {
+		App, Package, BaseURL string
+		Validate, Compare     bool
+		Cases                 []*testcase
+	}{App: appid, Package: pkg, BaseURL: baseURL, Cases: cases}
+	for _, tc := range cases {
+		data.Validate = data.Validate || tc.Schema != ""
+		data.Compare = data.Compare || (tc.Schema == "" && tc.Response != "")
 	}
 
-	rl := queryServletmocker(context.TODO(), appid, startTime)
-	tcs := make([]*testcase, 0)
-	for _, oneSevlet := range rl {
-		oCase := convertSevletToTestCase(oneSevlet)
-		tcs = append(tcs, oCase)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
 	}
-	return tcs
+	return format.Source(buf.Bytes())
+}
+
+// goIdentifier the go identifier of s, the runs of the other characters are replaced by an underscore
+func goIdentifier(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	id := strings.Join(strings.FieldsFunc(sb.String(), func(r rune) bool { return r == '_' }), "_")
+	if id == "" || unicode.IsDigit([]rune(id)[0]) {
+		id = "arex" + id
+	}
+	return id
 }
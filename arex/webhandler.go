@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -23,6 +23,18 @@ func InstallHandler(engine *gin.Engine) {
 	engine.PUT("/schema/:key", middleware, putSchema)
 	engine.PATCH("/schema/:key", middleware, patchSchema)
 	engine.DELETE("/schema/:key", middleware, deleteSchema)
+	engine.GET("/schema/:key/codegen", middleware, getSchemaCodegen)
+	engine.GET("/schema/:key/samples", middleware, getSchemaSamples)
+	engine.GET("/schema/:key/state", middleware, getSchemaState)
+	engine.PUT("/schema/:key/state", middleware, putSchemaState)
+	engine.GET("/drift/:key", middleware, getDrift)
+	engine.GET("/violations/:key", middleware, getViolations)
+
+	engine.GET("/proposals", middleware, getProposals)
+	engine.GET("/proposal/:id", middleware, getProposal)
+	engine.PUT("/proposal/:id", middleware, putProposal)
+	engine.POST("/proposal/:id/approve", middleware, postProposalApprove)
+	engine.POST("/proposal/:id/reject", middleware, postProposalReject)
 
 	engine.GET("/validation/:key", middleware, getValidation)
 	engine.POST("/validation", middleware, postValidation)
@@ -30,7 +42,20 @@ func InstallHandler(engine *gin.Engine) {
 	engine.POST("/comparing", middleware, postComparing)
 
 	engine.GET("/testcases/postman/:appid", middleware, getTestCasesOfPostman)
-	engine.GET("/testcases/golang/:appid", middleware, getTestCasesOfGolang)
+	engine.GET("/testcases/:format/:appid", middleware, getTestCasesOfFormat)
+
+	engine.GET("/openapi/:appid", middleware, getOpenAPI)
+
+	engine.POST("/learning", middleware, postLearning)
+	engine.POST("/decoding", middleware, postDecoding)
+	engine.POST("/import/har/:appid", middleware, postImportHAR)
+	engine.POST("/import/postman", middleware, postImportPostman)
+	engine.POST("/replay/validation", middleware, postReplayValidation)
+	engine.GET("/replay/validations", middleware, getReplayValidations)
+	engine.GET("/replay/validation/:id", middleware, getReplayValidation)
+
+	engine.GET("/contracts", middleware, getContracts)
+	engine.GET("/contract/:name", middleware, getContract)
 }
 
 func middleware(c *gin.Context) {
@@ -113,6 +138,71 @@ func getSchemaByKey(c *gin.Context) {
 
 }
 
+// getSchemaCodegen generate the models of the stored json-schema
+// @Summary      generate Go structs, Java POJOs or TypeScript interfaces by the json-schema of key
+// @Description  ?lang=go|java|ts the language of models, default go
+// @Description  ?name=Order the name of root model, default Data
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      text/plain
+// @Param        key   path   string  true   "schema key name"
+// @Param        lang  query  string  false  "go, java or ts"
+// @Param        name  query  string  false  "root model name"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "code"
+// @Fail         400  {string}  string "---"
+// @Router       /schema/{key}/codegen [get]
+func getSchemaCodegen(c *gin.Context) {
+	key := c.Param("key")
+	res := querySchema(context.Background(), key)
+	if res == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "schema not found"})
+		return
+	}
+	code, err := serviceCodegen(res, c.DefaultQuery("lang", jsonschema.LangGo), c.Query("name"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.String(http.StatusOK, code)
+}
+
+// getSchemaSamples generate the sample json of the stored json-schema
+// @Summary      generate valid, boundary or invalid sample json by the json-schema of key
+// @Description  ?mode=valid|boundary|invalid valid is default, boundary samples are on the lower and upper edges in turn,
+// @Description  invalid samples violate one keyword that is told by mutation
+// @Description  ?count=5&seed=1 the same seed generates the same samples
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key    path   string  true   "schema key name"
+// @Param        mode   query  string  false  "valid, boundary or invalid"
+// @Param        count  query  int     false  "count of samples, default 5"
+// @Param        seed   query  int     false  "random seed"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "[]sample"
+// @Fail         400  {string}  string "---"
+// @Router       /schema/{key}/samples [get]
+func getSchemaSamples(c *gin.Context) {
+	var opts sampleOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	key := c.Param("key")
+	res := querySchema(context.Background(), key)
+	if res == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "schema not found"})
+		return
+	}
+	samples, err := serviceSamples(res, opts)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, samples)
+}
+
 // postSchema    postSchema json-schema
 // @Summary      store json-schema to database by key
 // @Description  post data to store. path /keyName. Body {}json-schema
@@ -154,85 +244,102 @@ func postSchema(c *gin.Context) {
 // putSchema putSchema json-schema
 // @Summary      input json and parse json to schema, then save the schema by key
 // @Description  post /schema-key body contain origin json string {}
+// @Description  ?draft=4|6|7|2019-09|2020-12 choose the draft of generated json-schema, default 2020-12
+// @Description  ?defs=true hoist the repeated sub schemas into $defs and reference them by $ref
+// @Description  ?formats=date,uuid,uri detect these string formats in order, none disables the detection
+// @Description  ?numeric=exact|range|none&padding=0.5 bounds of numbers, ?multipleOf=true ?percentiles=true
 // @Tags         JSON-Schema
 // @Accept       application/json
 // @Produce      application/json
-// @Param        key  path  string  true  "schema key name"
-// @Param        body body  string  true  "{json}"
+// @Param        key         path   string  true   "schema key name"
+// @Param        draft       query  string  false  "json-schema draft version"
+// @Param        defs        query  bool    false  "extract reusable $defs"
+// @Param        formats     query  string  false  "formats to detect in order"
+// @Param        numeric     query  string  false  "exact, range or none"
+// @Param        padding     query  number  false  "padding ratio of range mode"
+// @Param        multipleOf  query  bool    false  "detect multipleOf"
+// @Param        percentiles query  bool    false  "annotate x-percentiles"
+// @Param        body        body   string  true   "{json}"
 // @Security     ApiKeyAuth
 // @Success      200  {string}  string "---"
 // @Fail         400  {string}  string "---"
 // @Router       /schema/{key} [put]
 func putSchema(c *gin.Context) {
-	compareSchemaToSave := func(key string, data []byte) *jsonschema.SchemaDocument {
-		res, err := serviceGenerateSchema(data)
+	compareSchemaToSave := func(key string, data []byte, opts generateOptions) (*jsonschema.SchemaDocument, error) {
+		res, err := serviceGenerateSchemaByOptions(data, opts)
 		if err != nil {
-			fmt.Println(err)
-			return nil
+			return nil, err
 		}
 		var ss schemaStore
 		ss.Key = key
 		storeData, err := json.Marshal(res.Document)
 		if err != nil {
-			fmt.Println(err)
-			return nil
+			return nil, err
 		}
 		ss.Schema = string(storeData)
 		saveSchema(context.Background(), ss)
-		return res.Document
+		return res.Document, nil
 	}
 
 	key := c.Param("key")
+	var opts generateOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "put failed:" + err.Error()})
+		return
+	}
 	jsonData, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": "put failed:" + err.Error()})
 		return
 	}
-	doc := compareSchemaToSave(key, jsonData)
+	doc, err := compareSchemaToSave(key, jsonData, opts)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "put failed:" + err.Error()})
+		return
+	}
 	c.IndentedJSON(http.StatusAccepted, doc)
 }
 
 // patchSchema   merge schema to existed schema
 // @Summary      patchSchema to merge new json to schema and merge to existed json-schema
 // @Description  post new json and parse it to merge existed json-schema
+// @Description  the merge is folded into the pending proposal of key, it replaces the schema once approved
+// @Description  the frozen and deprecated schemas reject the merge with 409
 // @Tags         JSON-Schema
 // @Accept       application/json
 // @Produce      application/json
 // @Param        key  path  string  true  "schema key name"
 // @Param        body body  string  true  "{}"
 // @Security     ApiKeyAuth
-// @Success      200  {string}  string "---"
+// @Success      202  {string}  string "proposal"
 // @Fail         400  {string}  string "---"
 // @Router       /schema/{key} [patch]
 func patchSchema(c *gin.Context) {
-	mergeSchemaByKey := func(key string, jsonData []byte) *jsonschema.SchemaDocument {
-		oldSchema := querySchema(context.Background(), key)
-		newschema, err := serviceUpdateSchema(oldSchema.Schema, jsonData)
-		if err != nil {
-			fmt.Println(err)
-			return nil
-		}
-
-		var ss schemaStore
-		ss.Key = key
-		storeData, err := json.Marshal(newschema)
-		if err != nil {
-			c.IndentedJSON(http.StatusConflict, gin.H{"message": "schema marshal error"})
-		}
-		ss.Schema = string(storeData)
-		saveSchema(context.Background(), ss)
-		return newschema
-	}
-
 	key := c.Param("key")
+	ss := querySchema(context.Background(), key)
+	if ss == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "schema not found"})
+		return
+	}
+	if ss.lifecycle() != schemaStateLearning {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "schema is " + ss.lifecycle() + ", it does not learn"})
+		return
+	}
 	jsonData, err := ioutil.ReadAll(c.Request.Body)
-	newschema := mergeSchemaByKey(key, jsonData)
-	if newschema == nil {
-		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": "put failed:" + err.Error()})
+	if err != nil {
+		c.IndentedJSON(http.StatusExpectationFailed, gin.H{"message": "patch failed:" + err.Error()})
 		return
 	}
-
-	c.IndentedJSON(http.StatusAccepted, newschema)
+	proposal, _, err := serviceProposeMerge(context.Background(), ss, jsonData)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "patch failed:" + err.Error()})
+		return
+	}
+	if proposal == nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "the merge does not change the schema"})
+		return
+	}
+	c.IndentedJSON(http.StatusAccepted, proposal)
 }
 
 // deleteSchema  delete json-schema by key
@@ -365,11 +472,14 @@ type comparing struct {
 	ValueX  string `json:"vx"`
 	ValueY  string `json:"vy"`
 	Options string `json:"options"`
+	Key     string `json:"key,omitempty"`
 }
 
 // postComparing  compare two json and get compared result
 // @Summary      compare json
 // @Description  post 2 json and return the difference
+// @Description  if key is set, every difference is annotated by the learned json-schema of key,
+// @Description  and only the differences that violate the schema are flagged as failure
 // @Tags         Comparing JSON
 // @Accept       application/json
 // @Produce      application/json
@@ -385,6 +495,16 @@ func postComparing(c *gin.Context) {
 		return
 	}
 
+	if compare.Key != "" {
+		diffs, err := serviceDiff2JSONBySchema(compare.ValueX, compare.ValueY, compare.Key)
+		if err != nil {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "compare failed:" + err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusCreated, diffs)
+		return
+	}
+
 	res := serviceDiff2JSON(compare.ValueX, compare.ValueY)
 	c.IndentedJSON(http.StatusCreated, res.Diffs)
 }
@@ -399,35 +519,562 @@ func postComparing(c *gin.Context) {
 // @Param        start  path string  true  "start date"
 // @Security     ApiKeyAuth
 // @Success      200  {string} string  "json data"
+// @Fail         400  {string}  string "---"
 // @Router       /testcases/postman/{appid} [get]
 func getTestCasesOfPostman(c *gin.Context) {
 	appid := c.Param("appid")
-	start := c.Query("start")
+	start, err := parseStartDate(c.Query("start"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 
 	val := exportAREXToPostman(appid, start)
 	c.IndentedJSON(http.StatusOK, val)
 }
 
-// getTestCasesOfGolang generate testcase that has golang format
-// @Summary      Query testcases json of golang
+// getTestCasesOfFormat generate testcase that has the format of a test tool
+// @Summary      Query testcases of golang, k6, jmeter, curl or http format, a file replaying the recordings of app
 // @Description  appid/?start=2022-2-22 limit the beggining
-// @Description  http Get /schemas
+// @Description  golang: a _test.go file, k6: a k6 script, jmeter: a JMeter test plan, curl: a shell script of curl, http: a .http file of VS Code and IntelliJ
+// @Description  ?baseurl=http://localhost:8080 the url of the service under test
+// @Description  ?package=shop the package of golang file, the app id by default
 // @Tags         Testcases
 // @Accept       application/json
 // @Produce      text/plain
-// @Param        start  path string  true  "start date"
+// @Param        format   path  string  true   "golang, k6, jmeter, curl or http"
+// @Param        start    query string  false  "start date"
+// @Param        baseurl  query string  false  "base url of the service"
+// @Param        package  query string  false  "package name of golang"
 // @Security     ApiKeyAuth
-// @Success      200  {string} string  "json data"
-// @Router       /testcases/golang/{appid} [get]
-func getTestCasesOfGolang(c *gin.Context) {
+// @Success      200  {string} string  "file of format"
+// @Fail         400  {string}  string "---"
+// @Fail         404  {string}  string "---"
+// @Router       /testcases/{format}/{appid} [get]
+func getTestCasesOfFormat(c *gin.Context) {
+	exporter, ok := testcaseExporters[c.Param("format")]
+	if !ok {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "unknown format, expected postman, golang, k6, jmeter, curl or http"})
+		return
+	}
 	appid := c.Param("appid")
-	start := c.Query("start")
+	start, err := parseStartDate(c.Query("start"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 
 	val := getTestCases(appid, start)
-	var caseText strings.Builder
-	for _, oneCase := range val {
-		caseText.WriteString(oneCase.ToCaseText())
-		caseText.WriteString("\r\n\r\n")
+	opts := &exportOptions{BaseURL: c.DefaultQuery("baseurl", "http://localhost:8080"), Package: c.Query("package")}
+	data, err := exporter.Export(appid, val, opts)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+exporter.FileName(appid))
+	c.Data(http.StatusOK, exporter.ContentType(), data)
+}
+
+// getContracts list the names of published contracts
+// @Summary      list the contracts reflected from go types
+// @Description  http Get /contracts
+// @Tags         Contracts
+// @Accept       application/json
+// @Produce      application/json
+// @Security     ApiKeyAuth
+// @Success      200  {string} string  "[]contract names"
+// @Router       /contracts [get]
+func getContracts(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, contractNames())
+}
+
+// getContract get the json-schema reflected from the go type of contract
+// @Summary      query the json-schema of contract by name
+// @Description  ?draft=4|6|7|2019-09|2020-12 choose the draft of json-schema, default 2020-12
+// @Tags         Contracts
+// @Accept       application/json
+// @Produce      application/json
+// @Param        name   path   string  true   "contract name"
+// @Param        draft  query  string  false  "json-schema draft version"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "{}"
+// @Fail         400  {string}  string "---"
+// @Router       /contract/{name} [get]
+func getContract(c *gin.Context) {
+	doc, err := serviceContract(c.Param("name"), c.Query("draft"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if doc == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "contract not found"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, doc)
+}
+
+// getOpenAPI export the learned schemas of app as OpenAPI document
+// @Summary      OpenAPI 3.1 document of the learned contracts of app
+// @Description  the paths are decoded from the schema keys of app, the methods and request bodies come from the recordings
+// @Tags         Contracts
+// @Accept       application/json
+// @Produce      application/json
+// @Param        appid  path  string  true  "app id"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "{}"
+// @Fail         400  {string}  string "---"
+// @Router       /openapi/{appid} [get]
+func getOpenAPI(c *gin.Context) {
+	doc, err := exportOpenAPI(context.Background(), c.Param("appid"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, doc)
+}
+
+// getDrift the drift events of the schema found by batch learning
+// @Summary      drift events of the json-schema of key, the newest first
+// @Description  kinds are new-field, field-disappeared, type-widened, type-conflict and format-lost,
+// @Description  recordid is the recording that caused the event. ?limit=100 is default
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key    path   string  true   "schema key name"
+// @Param        limit  query  int     false  "count of events, default 100"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "[]drift"
+// @Fail         400  {string}  string "---"
+// @Router       /drift/{key} [get]
+func getDrift(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid limit"})
+		return
+	}
+	records := queryDriftRecords(context.Background(), c.Param("key"), limit)
+	c.IndentedJSON(http.StatusOK, records)
+}
+
+// schemaState the lifecycle state of schema
+type schemaState struct {
+	State string `json:"state" binding:"required"`
+}
+
+// getSchemaState get the lifecycle state of schema
+// @Summary      lifecycle state of the json-schema of key
+// @Description  learning, frozen or deprecated, a schema is learning until it is frozen
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key  path  string  true  "schema key name"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "state"
+// @Fail         404  {string}  string "---"
+// @Router       /schema/{key}/state [get]
+func getSchemaState(c *gin.Context) {
+	res := querySchema(context.Background(), c.Param("key"))
+	if res == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "schema not found"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, schemaState{State: res.lifecycle()})
+}
+
+// putSchemaState set the lifecycle state of schema
+// @Summary      set the lifecycle state of the json-schema of key
+// @Description  learning: the batch job and PATCH merge samples into the schema
+// @Description  frozen: the merges are rejected, the batch job validates the samples against the schema and records the violations
+// @Description  deprecated: the samples are neither merged nor validated
+// @Description  the pending proposal of key is rejected when the schema is frozen or deprecated
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key   path  string  true  "schema key name"
+// @Param        body  body  string  true  "{state: learning, frozen or deprecated}"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "state"
+// @Fail         400  {string}  string "---"
+// @Fail         500  {string}  string "---"
+// @Router       /schema/{key}/state [put]
+func putSchemaState(c *gin.Context) {
+	var state schemaState
+	if err := c.ShouldBindJSON(&state); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := checkSchemaState(state.State); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !saveSchemaState(context.Background(), c.Param("key"), state.State) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "schema not found"})
+		return
+	}
+	if err := serviceCloseProposal(context.Background(), c.Param("key"), state.State); err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "close the pending proposal failed:" + err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, state)
+}
+
+// getViolations the violations of the frozen schema found by batch learning
+// @Summary      recordings that violate the frozen json-schema of key, the newest first
+// @Description  violations are the failed keywords of the recording, recordid is the recording. ?limit=100 is default
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key    path   string  true   "schema key name"
+// @Param        limit  query  int     false  "count of recordings, default 100"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "[]violation"
+// @Fail         400  {string}  string "---"
+// @Router       /violations/{key} [get]
+func getViolations(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid limit"})
+		return
+	}
+	records := queryViolationRecords(context.Background(), c.Param("key"), limit)
+	c.IndentedJSON(http.StatusOK, records)
+}
+
+// getProposals list the proposed revisions of schemas
+// @Summary      proposed revisions of schemas waiting for review, the newest first
+// @Description  ?key= the proposals of schema key, ?status=pending|approved|rejected, pending is default, all for any status
+// @Tags         Review
+// @Accept       application/json
+// @Produce      application/json
+// @Param        key     query  string  false  "schema key name"
+// @Param        status  query  string  false  "pending, approved, rejected or all"
+// @Param        limit   query  int     false  "count of proposals, default 100"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "[]proposal"
+// @Fail         400  {string}  string "---"
+// @Router       /proposals [get]
+func getProposals(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid limit"})
+		return
+	}
+	status := c.DefaultQuery("status", proposalPending)
+	switch status {
+	case "all":
+		status = ""
+	case proposalPending, proposalApproved, proposalRejected:
+	default:
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid status"})
+		return
+	}
+	proposals := queryProposals(context.Background(), c.Query("key"), status, limit)
+	c.IndentedJSON(http.StatusOK, proposals)
+}
+
+// getProposal the proposed revision of id
+// @Summary      proposed revision of schema, with the diffs against the current schema and the reviews
+// @Tags         Review
+// @Accept       application/json
+// @Produce      application/json
+// @Param        id  path  string  true  "proposal id"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "proposal"
+// @Fail         404  {string}  string "---"
+// @Router       /proposal/{id} [get]
+func getProposal(c *gin.Context) {
+	proposal := queryProposal(context.Background(), c.Param("id"))
+	if proposal == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": errProposalNotFound.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, proposal)
+}
+
+// putProposal edit the proposed schema
+// @Summary      replace the proposed schema by the edited one
+// @Description  body {"reviewer": "alice", "comment": "...", "schema": {json-schema}}, the diffs are computed again
+// @Tags         Review
+// @Accept       application/json
+// @Produce      application/json
+// @Param        id    path  string  true  "proposal id"
+// @Param        body  body  string  true  "review"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "proposal"
+// @Fail         400  {string}  string "---"
+// @Router       /proposal/{id} [put]
+func putProposal(c *gin.Context) {
+	reviewProposal(c, reviewEdit)
+}
+
+// postProposalApprove approve the proposal
+// @Summary      approve the proposal, the proposed schema replaces the schema of key
+// @Description  body {"reviewer": "alice", "comment": "..."}
+// @Tags         Review
+// @Accept       application/json
+// @Produce      application/json
+// @Param        id    path  string  true  "proposal id"
+// @Param        body  body  string  true  "review"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "proposal"
+// @Fail         400  {string}  string "---"
+// @Router       /proposal/{id}/approve [post]
+func postProposalApprove(c *gin.Context) {
+	reviewProposal(c, reviewApprove)
+}
+
+// postProposalReject reject the proposal
+// @Summary      reject the proposal, the schema of key is kept, the next merge opens a new proposal
+// @Description  body {"reviewer": "alice", "comment": "..."}
+// @Tags         Review
+// @Accept       application/json
+// @Produce      application/json
+// @Param        id    path  string  true  "proposal id"
+// @Param        body  body  string  true  "review"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "proposal"
+// @Fail         400  {string}  string "---"
+// @Router       /proposal/{id}/reject [post]
+func postProposalReject(c *gin.Context) {
+	reviewProposal(c, reviewReject)
+}
+
+func reviewProposal(c *gin.Context, action string) {
+	var review proposalReview
+	if err := c.ShouldBindJSON(&review); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	review.Action = action
+	proposal, err := serviceReviewProposal(context.Background(), c.Param("id"), &review)
+	switch {
+	case errors.Is(err, errProposalNotFound):
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+	case errors.Is(err, errProposalClosed), errors.Is(err, errSchemaNotLearning), errors.Is(err, errProposalConflict):
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": err.Error()})
+	case err != nil:
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	default:
+		c.IndentedJSON(http.StatusOK, proposal)
+	}
+}
+
+// postReplayValidation start the replay validation job
+// @Summary      learn the schemas from the baselines and validate the replays of a collection in background
+// @Description  body is the config of the collection, one document is a baseline/replay pair
+// @Description  {"collection": "ReplayCompareResultNew", "uri": "mongodb://...", "database": "db", "filter": {}, "limit": 1000,
+// @Description  "keyField": "", "serviceField": "service", "nameField": "resultname", "baselineField": "basemsg", "replayField": "testmsg",
+// @Description  "encoding": "gzip|zstd|none"}, the analysis database is used when uri is empty
+// @Tags         Replay
+// @Accept       application/json
+// @Produce      application/json
+// @Param        body  body  string  true  "replay config"
+// @Security     ApiKeyAuth
+// @Success      202  {string}  string "report"
+// @Fail         400  {string}  string "---"
+// @Fail         500  {string}  string "---"
+// @Router       /replay/validation [post]
+func postReplayValidation(c *gin.Context) {
+	var cfg replayConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := cfg.withDefaults(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	report, err := startReplayValidation(cfg)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusAccepted, report)
+}
+
+// getReplayValidations the reports of the replay validation job
+// @Summary      reports of the replay validation runs without cases, the newest first
+// @Tags         Replay
+// @Accept       application/json
+// @Produce      application/json
+// @Param        limit  query  int  false  "count of reports, default 20"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "[]report"
+// @Fail         400  {string}  string "---"
+// @Router       /replay/validations [get]
+func getReplayValidations(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid limit"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, queryReplayReports(context.Background(), limit))
+}
+
+// getReplayValidation the report of a run
+// @Summary      report of the replay validation run with the pass/fail of every case
+// @Description  ?status=passed|failed|error only the cases of status
+// @Tags         Replay
+// @Accept       application/json
+// @Produce      application/json
+// @Param        id      path   string  true   "report id"
+// @Param        status  query  string  false  "passed, failed or error"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "report"
+// @Fail         404  {string}  string "---"
+// @Router       /replay/validation/{id} [get]
+func getReplayValidation(c *gin.Context) {
+	report := queryReplayReport(context.Background(), c.Param("id"))
+	if report == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "report not found"})
+		return
+	}
+	report.Cases = queryReplayCases(context.Background(), report.ID, c.Query("status"))
+	c.IndentedJSON(http.StatusOK, report)
+}
+
+// postLearning learn the recordings of a source
+// @Summary      learn the schemas from the recordings of ServletMocker, a mongo collection or a local file
+// @Description  {"type": "servlet", "appid": "shop", "since": "2022-06-01T00:00:00Z"} the ServletMocker of app, all apps when appid is empty
+// @Description  {"type": "mongo", "uri": "mongodb://...", "database": "db", "collection": "records", "filter": {}, "limit": 1000,
+// @Description  "idField": "_id", "keyField": "key" or "serviceField": "service", "nameField": "operation", "bodyField": "response.body", "decoder": "none|base64|gzip|zstd"}
+// @Description  {"type": "file", "path": "shop.ndjson", "format": "ndjson", the fields as mongo} a line is a recording
+// @Description  {"type": "file", "path": "shop.har", "format": "har", "appid": "shop"} an entry is a recording of the servlet of app
+// @Tags         JSON-Schema
+// @Accept       application/json
+// @Produce      application/json
+// @Param        body  body  string  true  "source config"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "{recordings}"
+// @Fail         400  {string}  string "---"
+// @Router       /learning [post]
+func postLearning(c *gin.Context) {
+	var cfg sourceConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	source, err := cfg.source()
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	count, err := learnRecordings(context.Background(), source)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"recordings": count})
+}
+
+// postDecoding decode the recorded body by the sniffed codecs
+// @Summary      decode the recorded body, and tell the codecs applied in order
+// @Description  the codecs are sniffed by magic bytes: zstd, gzip, snappy, and base64 or brotli, they are chained until json or text
+// @Tags         Recordings
+// @Accept       text/plain
+// @Produce      application/json
+// @Param        body  body  string  true  "the recorded body"
+// @Security     ApiKeyAuth
+// @Success      200  {string}  string "{codecs, payload}"
+// @Fail         400  {string}  string "---"
+// @Router       /decoding [post]
+func postDecoding(c *gin.Context) {
+	data, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	decoded, codecs, err := payloadCodecs.Decode(data)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	var payload interface{} = string(decoded)
+	if json.Valid(decoded) {
+		payload = json.RawMessage(decoded)
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"codecs": codecs, "payload": payload})
+}
+
+// maxUploadBytes the most bytes of an uploaded file
+const maxUploadBytes = 64 << 20
+
+// uploadedFile the multipart file of form field file, or the request body, maxUploadBytes at most
+func uploadedFile(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.Request.Body, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return header.Open()
+}
+
+// postImportHAR import the HTTP Archive as the recordings of app
+// @Summary      import the json entries of HTTP Archive from browser devtools or proxies as the ServletMocker recordings of app
+// @Description  the har is the multipart file of form field file, or the request body. ?learn=true learns the schemas of the entries at once
+// @Description  the imported recordings are learned by the batch job, and exported as postman and golang testcases
+// @Tags         Recordings
+// @Accept       multipart/form-data,application/json
+// @Produce      application/json
+// @Param        appid  path   string  true   "app id"
+// @Param        learn  query  bool    false  "learn the schemas at once"
+// @Param        file   formData  file  false  "har file"
+// @Security     ApiKeyAuth
+// @Success      201  {string}  string "{mockers, skipped, recordings}"
+// @Fail         400  {string}  string "---"
+// @Router       /import/har/{appid} [post]
+func postImportHAR(c *gin.Context) {
+	body, err := uploadedFile(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	defer body.Close()
+	var har harDocument
+	if err := json.NewDecoder(body).Decode(&har); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "har: " + err.Error()})
+		return
+	}
+	res, err := serviceImportHAR(context.Background(), &har, c.Param("appid"), c.Query("learn") == "true")
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusCreated, res)
+}
+
+// postImportPostman import the postman collection as the recordings and schemas of app
+// @Summary      import the requests of postman collection v2.1 as the ServletMocker recordings of app, and learn their schemas
+// @Description  the collection is the multipart file of form field file, or the request body. the folders are walked recursively
+// @Description  the json request bodies and example responses are learned into the schemas of appid-base64url(path)
+// @Tags         Recordings
+// @Accept       multipart/form-data,application/json
+// @Produce      application/json
+// @Param        appid  query  string  false  "app id, the collection name by default"
+// @Param        file   formData  file  false  "postman collection"
+// @Security     ApiKeyAuth
+// @Success      201  {string}  string "{appid, mockers, skipped, recordings}"
+// @Fail         400  {string}  string "---"
+// @Router       /import/postman [post]
+func postImportPostman(c *gin.Context) {
+	body, err := uploadedFile(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	defer body.Close()
+	var root Root
+	if err := json.NewDecoder(body).Decode(&root); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "collection: " + err.Error()})
+		return
+	}
+	res, err := serviceImportPostman(context.Background(), &root, c.Query("appid"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
 	}
-	c.String(http.StatusOK, caseText.String())
+	c.IndentedJSON(http.StatusCreated, res)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"strconv"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
@@ -20,6 +21,24 @@ type DifferItem struct {
 	Vy         string   `json:"vy,omitempty"`
 }
 
+// JSONPath return the json keys and array indexes of the different item
+func (d *DifferItem) JSONPath() []string {
+	keys := make([]string, 0)
+	for _, step := range d.StructPath {
+		switch ps := step.(type) {
+		case cmp.MapIndex:
+			keys = append(keys, fmt.Sprintf("%v", ps.Key().Interface()))
+		case cmp.SliceIndex:
+			ix, iy := ps.SplitKeys()
+			if iy < 0 {
+				iy = ix
+			}
+			keys = append(keys, strconv.Itoa(iy))
+		}
+	}
+	return keys
+}
+
 // DiffReporter is a simple custom reporter that only records differences
 // detected during comparison.
 type DiffReporter struct {
@@ -39,7 +58,7 @@ func (r *DiffReporter) Report(rs cmp.Result) {
 		var d DifferItem
 		// d.Path = fmt.Sprintf("%#v", r.path)
 		d.Path = r.path.GoString()
-		d.StructPath = r.path
+		d.StructPath = append(cmp.Path{}, r.path...)
 		if vx.Kind() != reflect.Invalid {
 			d.Vx = fmt.Sprintf("%+v", vx)
 		}
@@ -291,3 +291,16 @@ func Test_Compare2Schema(t *testing.T) {
 	res := GoCmpDiffByFile("../testdata/grafana_schema.json", "../testdata/grafana_schema_1.json")
 	fmt.Println(res)
 }
+
+func Test_JSONPath(t *testing.T) {
+	x := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 2.0}}}
+	y := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 3.0}}}
+	res := GoCmpDiff(x, y)
+	if len(res.Diffs) != 1 {
+		t.Fatalf("expect 1 diff, got %d", len(res.Diffs))
+	}
+	path := res.Diffs[0].JSONPath()
+	if !reflect.DeepEqual(path, []string{"a", "b", "1"}) {
+		t.Errorf("unexpected path %v", path)
+	}
+}
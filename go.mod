@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/DataDog/zstd v1.5.2
+	github.com/andybalholm/brotli v1.0.4
 	github.com/deckarep/golang-set v1.8.0
 	github.com/gin-gonic/gin v1.8.1
 	github.com/goccy/go-json v0.9.7
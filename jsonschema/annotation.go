@@ -0,0 +1,146 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// volatileFormats formats that change on every request, such as timestamp and uuid
+var volatileFormats = map[string]bool{
+	"date-time": true,
+	"time":      true,
+	"uuid":      true,
+}
+
+const (
+	constVolatileMinSamples = 5
+	constVolatileRatio      = 0.8
+)
+
+// PathAnnotation schema context of one json path
+type PathAnnotation struct {
+	Path     string `json:"path"`
+	Known    bool   `json:"known"`
+	Type     string `json:"type,omitempty"`
+	Required bool   `json:"required"`
+	Valid    bool   `json:"valid"`
+	Volatile bool   `json:"volatile"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Annotate returns the schema context of json path keys, and validates value by the schema of the path.
+// exist is false when the value has been removed from the json
+func (d *SchemaDocument) Annotate(keys []string, value interface{}, exist bool) *PathAnnotation {
+	pa := &PathAnnotation{Path: "/" + strings.Join(keys, "/")}
+
+	p, parent, required := d.property.lookup(keys)
+	pa.Required = required
+	if p == nil {
+		// unknown field is valid, unless the parent forbids additional properties
+		pa.Valid = !exist || parent == nil || parent.AdditionalProperties != false
+		if !pa.Valid {
+			pa.Message = "additional property is not allowed"
+		}
+		return pa
+	}
+
+	pa.Known = true
+	pa.Type = p.Type
+	pa.Volatile = p.isVolatile()
+	if !exist {
+		pa.Valid = !required
+		if !pa.Valid {
+			pa.Message = "required property is missing"
+		}
+		return pa
+	}
+
+	if err := d.validateByProperty(p, value); err != nil {
+		pa.Message = err.Error()
+		return pa
+	}
+	pa.Valid = true
+	return pa
+}
+
+// validateByProperty compile the sub property as a standalone schema and validate value.
+// the $defs of root are carried over, so that the $ref in the sub property resolves
+func (d *SchemaDocument) validateByProperty(p *property, value interface{}) error {
+	sub := SchemaDocument{Schema: d.Schema, property: *p}
+	if len(d.Defs) > 0 {
+		sub.Defs = d.Defs
+	}
+	text, err := json.Marshal(&sub)
+	if err != nil {
+		return err
+	}
+	schema, err := CompileString("annotation.json", string(text))
+	if err != nil {
+		return err
+	}
+	return schema.Validate(value)
+}
+
+// lookup find the property of json path keys, return the property, its parent and whether it is required.
+func (p *property) lookup(keys []string) (*property, *property, bool) {
+	cur := p
+	var parent *property
+	required := true
+	for _, key := range keys {
+		if cur.Ref != nil {
+			cur = cur.Ref
+		}
+		parent = cur
+		switch {
+		case cur.PrefixItems != nil:
+			i, err := strconv.Atoi(key)
+			if err == nil && i >= 0 && i < len(cur.PrefixItems) {
+				cur = cur.PrefixItems[i]
+			} else {
+				cur = nil
+			}
+			required = false
+		case cur.Items != nil:
+			cur = cur.Items
+			required = false
+		case cur.Properties != nil:
+			cur = cur.Properties[key]
+			required = contains(parent.Required, key)
+		default:
+			cur = nil
+		}
+		if cur == nil {
+			return nil, parent, false
+		}
+	}
+	if cur.Ref != nil {
+		cur = cur.Ref
+	}
+	return cur, parent, required
+}
+
+// isVolatile high cardinality field, its value differs in almost every sample
+func (p *property) isVolatile() bool {
+	if volatileFormats[p.Format] {
+		return true
+	}
+	if len(p.Examples) < constVolatileMinSamples {
+		return false
+	}
+	distinct := make(map[string]struct{})
+	for _, v := range p.Examples {
+		b, _ := json.Marshal(v)
+		distinct[string(b)] = struct{}{}
+	}
+	return float64(len(distinct))/float64(len(p.Examples)) >= constVolatileRatio
+}
+
+func contains(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
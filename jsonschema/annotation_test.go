@@ -0,0 +1,81 @@
+package jsonschema
+
+import (
+	"testing"
+)
+
+func Test_Annotate(t *testing.T) {
+	data := []byte(`{"id":"c8e2b3a0-7f4e-4bb8-9d6b-2f1f3e2b7c11","name":"arex","age":3,"tags":["a"]}`)
+	m, err := GenerateSchemaDataModel(data, "annotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := m.Document.Annotate([]string{"name"}, "xrea", true)
+	if !a.Known || !a.Required || !a.Valid {
+		t.Errorf("name should be known, required and valid: %+v", a)
+	}
+
+	a = m.Document.Annotate([]string{"name"}, 12.0, true)
+	if a.Valid {
+		t.Errorf("number should not validate against string: %+v", a)
+	}
+
+	a = m.Document.Annotate([]string{"name"}, nil, false)
+	if a.Valid {
+		t.Errorf("removed required field should be invalid: %+v", a)
+	}
+
+	a = m.Document.Annotate([]string{"tags", "0"}, "b", true)
+	if !a.Known || a.Required || !a.Valid {
+		t.Errorf("array item should be known and not required: %+v", a)
+	}
+
+	a = m.Document.Annotate([]string{"unknown"}, "x", true)
+	if a.Known || !a.Valid {
+		t.Errorf("unknown field should be valid: %+v", a)
+	}
+}
+
+func Test_AnnotateVolatile(t *testing.T) {
+	p := &property{Type: "string"}
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		p.Examples = append(p.Examples, v)
+	}
+	if !p.isVolatile() {
+		t.Error("distinct examples should be volatile")
+	}
+
+	p.Examples = []interface{}{"a", "a", "a", "a", "b"}
+	if p.isVolatile() {
+		t.Error("repeated examples should not be volatile")
+	}
+
+	if !(&property{Type: "string", Format: "date-time"}).isVolatile() {
+		t.Error("date-time should be volatile")
+	}
+}
+
+func Test_AnnotateRef(t *testing.T) {
+	text := `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object",
+		"properties": {"from": {"$ref": "#/$defs/address"}, "to": {"$ref": "#/$defs/address"},
+			"stops": {"type": "array", "items": {"$ref": "#/$defs/address"}}},
+		"$defs": {"address": {"type": "object", "properties": {"city": {"type": "string"}, "next": {"$ref": "#/$defs/address"}},
+			"required": ["city"]}}}`
+	var d SchemaDocument
+	if err := d.UnmarshalJSON([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	a := d.Annotate([]string{"from"}, map[string]interface{}{"city": "paris", "next": map[string]interface{}{"city": "rome"}}, true)
+	if !a.Known || !a.Valid {
+		t.Errorf("the address should be valid: %+v", a)
+	}
+	a = d.Annotate([]string{"stops", "0"}, map[string]interface{}{"next": map[string]interface{}{}}, true)
+	if a.Valid {
+		t.Errorf("the address without city should be invalid: %+v", a)
+	}
+	a = d.Annotate([]string{"to", "next"}, map[string]interface{}{"city": "rome"}, true)
+	if !a.Known || !a.Valid {
+		t.Errorf("the nested address should be valid: %+v", a)
+	}
+}
@@ -0,0 +1,574 @@
+package jsonschema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// code generation languages of WriteCode
+const (
+	LangGo         = "go"
+	LangJava       = "java"
+	LangTypeScript = "ts"
+)
+
+// CheckCodeLang returns error when the code generation language is unknown
+func CheckCodeLang(lang string) error {
+	switch lang {
+	case LangGo, LangJava, LangTypeScript:
+		return nil
+	}
+	return fmt.Errorf("unsupported language %q", lang)
+}
+
+// WriteCode write the models of the schema document in lang, the root model is named by name.
+// objects of $defs and nested objects are models, string enums are enum types, $ref refers the model of $defs.
+// go models are the file of package model, ts models are the declarations only, java models are the static classes of the root class.
+func (d *SchemaDocument) WriteCode(w io.Writer, lang string, name string) error {
+	if err := CheckCodeLang(lang); err != nil {
+		return err
+	}
+	if name == "" {
+		name = "Data"
+	}
+	g := &codeGenerator{
+		lang:  lang,
+		root:  &d.property,
+		names: make(map[*property]string),
+		used:  make(map[string]bool),
+	}
+	g.collect(replaceName(name))
+
+	switch lang {
+	case LangGo:
+		var buf bytes.Buffer
+		g.writeGo(&buf)
+		b, err := format.Source(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case LangJava:
+		g.writeJava(w)
+	default:
+		g.writeTypeScript(w)
+	}
+	return nil
+}
+
+// codeModel the named type of generated code, an object with fields, a string enum or an alias of other type
+type codeModel struct {
+	Name        string
+	Description string
+	Schema      *property
+	Fields      []*codeField
+	Enum        []string
+	Alias       string // the type of root that is not an object
+}
+
+// codeField the field of object model
+type codeField struct {
+	Key         string // name of json property
+	Name        string // identifier of field
+	Description string
+	Required    bool
+	Nullable    bool
+	Type        string
+	Scalar      bool // the go type has zero value, so the optional field is a pointer
+}
+
+type codeGenerator struct {
+	lang   string
+	root   *property
+	models []*codeModel
+	names  map[*property]string // the type names of the properties that are models
+	used   map[string]bool
+}
+
+// collect name the root and $defs first, so $ref resolves to the model name no matter the order
+func (g *codeGenerator) collect(name string) {
+	g.names[g.root] = g.uniqueName(name)
+	defNames := make([]string, 0, len(g.root.Defs))
+	for defName := range g.root.Defs {
+		defNames = append(defNames, defName)
+	}
+	sort.Strings(defNames)
+	for _, defName := range defNames {
+		if def := g.root.Defs[defName]; def != g.root {
+			g.names[def] = g.uniqueName(replaceName(defName))
+		}
+	}
+
+	rootModel := &codeModel{Name: g.names[g.root], Description: g.root.Description, Schema: g.root}
+	g.models = append(g.models, rootModel)
+	if isObjectModel(g.root) {
+		g.readFields(rootModel)
+	} else {
+		rootModel.Alias, _ = g.typeOf(g.root, rootModel.Name+"Item")
+	}
+	for _, defName := range defNames {
+		if def := g.root.Defs[defName]; def != g.root {
+			g.model(def, g.names[def])
+		}
+	}
+}
+
+func (g *codeGenerator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.used[unique] = true
+	return unique
+}
+
+// model the model of object or string enum named by name, returns the type name.
+func (g *codeGenerator) model(p *property, name string) string {
+	for _, m := range g.models {
+		if m.Schema == p {
+			return m.Name
+		}
+	}
+	if named, ok := g.names[p]; ok {
+		name = named
+	} else {
+		name = g.uniqueName(name)
+		g.names[p] = name
+	}
+	m := &codeModel{Name: name, Description: p.Description, Schema: p}
+	g.models = append(g.models, m)
+	if enum, ok := stringEnum(p); ok {
+		m.Enum = enum
+	} else if isObjectModel(p) {
+		g.readFields(m)
+	} else {
+		m.Alias, _ = g.typeOf(p, name+"Item")
+	}
+	return name
+}
+
+func (g *codeGenerator) readFields(m *codeModel) {
+	p := m.Schema
+	keys := make([]string, 0, len(p.Properties))
+	for key := range p.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := make(map[string]bool)
+	for _, key := range keys {
+		fp := p.Properties[key]
+		base := replaceName(key)
+		if g.lang == LangJava {
+			base = strings.ToLower(base[:1]) + base[1:]
+		}
+		name := base
+		for i := 2; names[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		names[name] = true
+
+		t, nullable := g.typeOf(fp, m.Name+replaceName(key))
+		m.Fields = append(m.Fields, &codeField{
+			Key:         key,
+			Name:        name,
+			Description: fp.Description,
+			Required:    contains(p.Required, key),
+			Nullable:    nullable,
+			Type:        t,
+			Scalar:      isGoScalar(t),
+		})
+	}
+}
+
+// typeOf the type of property in the language, and whether it is nullable.
+// hint names the model of nested object or enum.
+func (g *codeGenerator) typeOf(p *property, hint string) (string, bool) {
+	if p.Ref != nil {
+		return g.model(p.Ref, hint), false
+	}
+	// anyOf [T, null] is the nullable T
+	if variants := append(append([]*property{}, p.AnyOf...), p.OneOf...); len(variants) == 2 {
+		for i, v := range variants {
+			if v.Type == "null" {
+				t, _ := g.typeOf(variants[1-i], hint)
+				return t, true
+			}
+		}
+	}
+
+	types, nullable := nonNullTypes(p)
+	if len(types) != 1 {
+		return g.anyType(), nullable
+	}
+	if _, ok := stringEnum(p); ok {
+		return g.model(p, hint), nullable
+	}
+
+	switch types[0] {
+	case "object":
+		if isObjectModel(p) {
+			return g.model(p, hint), nullable
+		}
+		if ap, ok := p.AdditionalProperties.(*property); ok {
+			t, _ := g.typeOf(ap, hint+"Value")
+			return g.mapType(t), nullable
+		}
+		return g.mapType(g.anyType()), nullable
+	case "array":
+		if p.Items != nil && len(p.PrefixItems) == 0 {
+			t, _ := g.typeOf(p.Items, hint+"Item")
+			return g.arrayType(t), nullable
+		}
+		return g.arrayType(g.anyType()), nullable
+	}
+	return g.scalarType(types[0], p.Format), nullable
+}
+
+// nonNullTypes the types of property except null, and whether null is allowed
+func nonNullTypes(p *property) ([]string, bool) {
+	types := p.Types
+	if p.Type != "" {
+		types = []string{p.Type}
+	}
+	if len(types) == 0 && len(p.Properties) > 0 {
+		types = []string{"object"}
+	}
+	nonNull := make([]string, 0, len(types))
+	for _, t := range types {
+		if t != "null" {
+			nonNull = append(nonNull, t)
+		}
+	}
+	return nonNull, len(nonNull) < len(types)
+}
+
+func isObjectModel(p *property) bool {
+	types, _ := nonNullTypes(p)
+	return len(types) == 1 && types[0] == "object" && len(p.Properties) > 0
+}
+
+// stringEnum the string values of enum, ok only when all the values but null are strings
+func stringEnum(p *property) ([]string, bool) {
+	var values []string
+	for _, v := range p.Enum {
+		switch vv := v.(type) {
+		case string:
+			values = append(values, vv)
+		case nil:
+		default:
+			return nil, false
+		}
+	}
+	return values, len(values) > 0
+}
+
+func (g *codeGenerator) anyType() string {
+	switch g.lang {
+	case LangGo:
+		return "interface{}"
+	case LangJava:
+		return "Object"
+	}
+	return "unknown"
+}
+
+func (g *codeGenerator) mapType(value string) string {
+	switch g.lang {
+	case LangGo:
+		return "map[string]" + value
+	case LangJava:
+		return "Map<String, " + value + ">"
+	}
+	return "Record<string, " + value + ">"
+}
+
+func (g *codeGenerator) arrayType(item string) string {
+	switch g.lang {
+	case LangGo:
+		return "[]" + item
+	case LangJava:
+		return "List<" + item + ">"
+	}
+	if strings.Contains(item, " ") {
+		item = "(" + item + ")"
+	}
+	return item + "[]"
+}
+
+// scalarType the type of json scalar and format. java types are boxed, so the absence is null
+func (g *codeGenerator) scalarType(jsType string, format string) string {
+	switch g.lang {
+	case LangGo:
+		switch jsType {
+		case "integer":
+			return "int64"
+		case "number":
+			return "float64"
+		case "boolean":
+			return "bool"
+		case "string":
+			if format == "date-time" {
+				return "time.Time"
+			}
+			return "string"
+		}
+	case LangJava:
+		switch jsType {
+		case "integer":
+			return "Long"
+		case "number":
+			return "Double"
+		case "boolean":
+			return "Boolean"
+		case "string":
+			if format == "uuid" {
+				return "UUID"
+			}
+			return "String"
+		}
+	default:
+		switch jsType {
+		case "integer", "number":
+			return "number"
+		case "boolean":
+			return "boolean"
+		case "string":
+			return "string"
+		}
+	}
+	return g.anyType()
+}
+
+// isGoScalar the go type that has no nil value
+func isGoScalar(t string) bool {
+	return !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") && t != "interface{}"
+}
+
+// constGoPackage the package of the generated go file
+const constGoPackage = "model"
+
+func (g *codeGenerator) writeGo(w io.Writer) {
+	var body bytes.Buffer
+	g.writeGoModels(&body)
+	fmt.Fprintf(w, "package %s\n\n", constGoPackage)
+	if bytes.Contains(body.Bytes(), []byte("time.Time")) {
+		fmt.Fprint(w, "import \"time\"\n\n")
+	}
+	w.Write(body.Bytes())
+}
+
+func (g *codeGenerator) writeGoModels(w io.Writer) {
+	recursive := g.recursiveGoFields()
+	for _, m := range g.models {
+		writeComment(w, "// ", m.Name, m.Description)
+		switch {
+		case m.Enum != nil:
+			fmt.Fprintf(w, "type %s string\n\nconst (\n", m.Name)
+			for _, value := range m.Enum {
+				fmt.Fprintf(w, "%s%s %s = %q\n", m.Name, replaceName(value), m.Name, value)
+			}
+			fmt.Fprint(w, ")\n\n")
+		case m.Alias != "":
+			fmt.Fprintf(w, "type %s %s\n\n", m.Name, m.Alias)
+		default:
+			fmt.Fprintf(w, "type %s struct {\n", m.Name)
+			for _, f := range m.Fields {
+				t, tag := f.Type, f.Key
+				if (!f.Required || f.Nullable || recursive[f]) && f.Scalar {
+					t = "*" + t
+				}
+				if !f.Required {
+					tag += ",omitempty"
+				}
+				fmt.Fprintf(w, "%s %s `json:\"%s\"`", f.Name, t, tag)
+				if f.Description != "" {
+					fmt.Fprintf(w, " // %s", oneLine(f.Description))
+				}
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, "}\n\n")
+		}
+	}
+}
+
+// recursiveGoFields the required struct fields that contain their own model by value, such as the parent of a tree node.
+// they are pointers, otherwise the struct would have infinite size
+func (g *codeGenerator) recursiveGoFields() map[*codeField]bool {
+	structs := make(map[string]*codeModel)
+	for _, m := range g.models {
+		if m.Enum == nil && m.Alias == "" {
+			structs[m.Name] = m
+		}
+	}
+	// the models of the fields that are values
+	values := func(m *codeModel) []*codeField {
+		var fields []*codeField
+		for _, f := range m.Fields {
+			if f.Required && !f.Nullable && structs[f.Type] != nil {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	}
+	var reaches func(from, to *codeModel, visited map[*codeModel]bool) bool
+	reaches = func(from, to *codeModel, visited map[*codeModel]bool) bool {
+		if from == to {
+			return true
+		}
+		if visited[from] {
+			return false
+		}
+		visited[from] = true
+		for _, f := range values(from) {
+			if reaches(structs[f.Type], to, visited) {
+				return true
+			}
+		}
+		return false
+	}
+
+	recursive := make(map[*codeField]bool)
+	for _, m := range g.models {
+		for _, f := range values(m) {
+			if reaches(structs[f.Type], m, make(map[*codeModel]bool)) {
+				recursive[f] = true
+			}
+		}
+	}
+	return recursive
+}
+
+func (g *codeGenerator) writeJava(w io.Writer) {
+	fmt.Fprint(w, "package com.example.gson;\n\n")
+	fmt.Fprint(w, "import com.google.gson.annotations.SerializedName;\n")
+	fmt.Fprint(w, "import java.util.List;\nimport java.util.Map;\nimport java.util.UUID;\n\n")
+	for i, m := range g.models {
+		indent := ""
+		modifier := "public"
+		if i > 0 {
+			indent = "\t"
+			modifier = "public static"
+		}
+		writeComment(w, indent+"// ", m.Name, m.Description)
+		switch {
+		case m.Enum != nil:
+			fmt.Fprintf(w, "%s%s enum %s {\n", indent, modifier, m.Name)
+			for j, value := range m.Enum {
+				sep := ","
+				if j == len(m.Enum)-1 {
+					sep = ";"
+				}
+				fmt.Fprintf(w, "%s\t@SerializedName(%q)\n%s\t%s%s\n", indent, value, indent, javaConstant(value), sep)
+			}
+			fmt.Fprintf(w, "%s}\n\n", indent)
+			continue
+		case m.Alias != "":
+			// java has no type alias, the root is wrapped as value
+			m.Fields = []*codeField{{Key: "value", Name: "value", Type: m.Alias}}
+		}
+		fmt.Fprintf(w, "%s%s class %s {\n", indent, modifier, m.Name)
+		for _, f := range m.Fields {
+			if f.Description != "" {
+				fmt.Fprintf(w, "%s\t// %s\n", indent, oneLine(f.Description))
+			}
+			if f.Required && !f.Nullable {
+				fmt.Fprintf(w, "%s\t// required\n", indent)
+			}
+			fmt.Fprintf(w, "%s\t@SerializedName(%q)\n%s\tprivate %s %s;\n\n", indent, f.Key, indent, f.Type, f.Name)
+		}
+		for _, f := range m.Fields {
+			accessor := strings.ToUpper(f.Name[:1]) + f.Name[1:]
+			fmt.Fprintf(w, "%s\tpublic %s get%s() {\n%s\t\treturn %s;\n%s\t}\n\n", indent, f.Type, accessor, indent, f.Name, indent)
+			fmt.Fprintf(w, "%s\tpublic void set%s(%s %s) {\n%s\t\tthis.%s = %s;\n%s\t}\n\n",
+				indent, accessor, f.Type, f.Name, indent, f.Name, f.Name, indent)
+		}
+		if i > 0 {
+			fmt.Fprintf(w, "%s}\n\n", indent)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func (g *codeGenerator) writeTypeScript(w io.Writer) {
+	for _, m := range g.models {
+		writeComment(w, "// ", m.Name, m.Description)
+		switch {
+		case m.Enum != nil:
+			values := make([]string, 0, len(m.Enum))
+			for _, value := range m.Enum {
+				values = append(values, strconv.Quote(value))
+			}
+			fmt.Fprintf(w, "export type %s = %s;\n\n", m.Name, strings.Join(values, " | "))
+		case m.Alias != "":
+			fmt.Fprintf(w, "export type %s = %s;\n\n", m.Name, m.Alias)
+		default:
+			fmt.Fprintf(w, "export interface %s {\n", m.Name)
+			for _, f := range m.Fields {
+				if f.Description != "" {
+					fmt.Fprintf(w, "  /** %s */\n", oneLine(f.Description))
+				}
+				key, t := f.Key, f.Type
+				if !isIdentifier(key) {
+					key = strconv.Quote(key)
+				}
+				if !f.Required {
+					key += "?"
+				}
+				if f.Nullable {
+					t += " | null"
+				}
+				fmt.Fprintf(w, "  %s: %s;\n", key, t)
+			}
+			fmt.Fprint(w, "}\n\n")
+		}
+	}
+}
+
+func writeComment(w io.Writer, prefix string, name string, description string) {
+	if description != "" {
+		fmt.Fprintf(w, "%s%s %s\n", prefix, name, oneLine(description))
+	}
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// javaConstant the enum constant of value, such as in-progress to IN_PROGRESS
+func javaConstant(value string) string {
+	var sb strings.Builder
+	for i, r := range strings.ToUpper(value) {
+		switch {
+		case r >= 'A' && r <= 'Z' || r == '_' || r >= '0' && r <= '9' && i > 0:
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			sb.WriteString("_")
+			sb.WriteRune(r)
+		default:
+			sb.WriteString("_")
+		}
+	}
+	if sb.Len() == 0 {
+		return "EMPTY"
+	}
+	return sb.String()
+}
+
+// isIdentifier the property name can be an unquoted key of typescript
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+const codegenSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "status", "createdAt", "lines"],
+	"properties": {
+		"id": {"type": "integer", "description": "order id"},
+		"status": {"type": "string", "enum": ["NEW", "in-progress"]},
+		"createdAt": {"type": "string", "format": "date-time"},
+		"note": {"type": ["string", "null"]},
+		"lines": {"type": "array", "items": {"$ref": "#/$defs/line"}},
+		"buyer": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"tags": {"type": "object", "additionalProperties": {"type": "string"}},
+		"trace-id": {"type": "string", "format": "uuid"}
+	},
+	"$defs": {
+		"line": {
+			"type": "object",
+			"required": ["sku", "bundle"],
+			"properties": {
+				"sku": {"type": "string"},
+				"price": {"type": "number"},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/line"}},
+				"bundle": {"$ref": "#/$defs/bundle"}
+			}
+		},
+		"bundle": {
+			"type": "object",
+			"required": ["line"],
+			"properties": {"line": {"$ref": "#/$defs/line"}}
+		}
+	}
+}`
+
+func Test_WriteCode(t *testing.T) {
+	var doc SchemaDocument
+	if err := json.Unmarshal([]byte(codegenSchema), &doc); err != nil {
+		t.Fatal(err)
+	}
+	code := func(lang string) string {
+		var buf bytes.Buffer
+		if err := doc.WriteCode(&buf, lang, "order"); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	goCode := code(LangGo)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "order.go", goCode, 0)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, goCode)
+	}
+	// the recursive structs have size
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("model", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("%v\n%s", err, goCode)
+	}
+	// gofmt aligns the fields
+	flatCode := strings.Join(strings.Fields(goCode), " ")
+	for _, expected := range []string{
+		"type Order struct",
+		"ID int64 `json:\"id\"` // order id",
+		"Status OrderStatus `json:\"status\"`",
+		"CreatedAt time.Time `json:\"createdAt\"`",
+		"Note *string `json:\"note,omitempty\"`",
+		"Lines []Line `json:\"lines\"`",
+		"Buyer *OrderBuyer `json:\"buyer,omitempty\"`",
+		"Tags map[string]string `json:\"tags,omitempty\"`",
+		"OrderStatusInProgress OrderStatus = \"in-progress\"",
+		"Children []Line `json:\"children,omitempty\"`",
+		"Bundle *Bundle `json:\"bundle\"`",
+		"Line *Line `json:\"line\"`",
+	} {
+		if !strings.Contains(flatCode, expected) {
+			t.Errorf("go: %s is not generated\n%s", expected, goCode)
+		}
+	}
+
+	javaCode := code(LangJava)
+	for _, expected := range []string{
+		"public class Order {",
+		"public static enum OrderStatus {",
+		"IN_PROGRESS;",
+		"private List<Line> lines;",
+		"private UUID traceID;",
+		"public static class Line {",
+		"public void setChildren(List<Line> children) {",
+	} {
+		if !strings.Contains(javaCode, expected) {
+			t.Errorf("java: %s is not generated\n%s", expected, javaCode)
+		}
+	}
+	if strings.Count(javaCode, "{") != strings.Count(javaCode, "}") {
+		t.Errorf("java: braces are not balanced\n%s", javaCode)
+	}
+
+	tsCode := code(LangTypeScript)
+	for _, expected := range []string{
+		"export interface Order {",
+		"export type OrderStatus = \"NEW\" | \"in-progress\";",
+		"  id: number;",
+		"  note?: string | null;",
+		"  \"trace-id\"?: string;",
+		"  tags?: Record<string, string>;",
+		"  children?: Line[];",
+	} {
+		if !strings.Contains(tsCode, expected) {
+			t.Errorf("ts: %s is not generated\n%s", expected, tsCode)
+		}
+	}
+
+	if err := doc.WriteCode(&bytes.Buffer{}, "rust", "order"); err == nil {
+		t.Error("unsupported language should be reported")
+	}
+}
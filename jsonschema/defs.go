@@ -0,0 +1,294 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const constDefsPrefix = "#/$defs/"
+
+// ExtractDefs hoist the structurally identical object sub schemas into $defs,
+// and replace every occurrence with $ref. The validation behavior keeps identical.
+func (d *SchemaDocument) ExtractDefs() {
+	counts := make(map[string]int)
+	d.property.countStructures(counts)
+
+	defs := make(map[string]*property)
+	if d.Defs == nil {
+		d.Defs = make(map[string]*property)
+	}
+	d.property.hoistStructures(counts, defs, d.Defs)
+	if len(d.Defs) == 0 {
+		d.Defs = nil
+	}
+}
+
+// eachSubProperty call fn with the name and slot of every direct sub property
+func (p *property) eachSubProperty(fn func(name string, slot **property)) {
+	for _, key := range sortedPropertyKeys(p.Properties) {
+		value := p.Properties[key]
+		fn(key, &value)
+		p.Properties[key] = value
+	}
+	if ap, ok := p.AdditionalProperties.(*property); ok {
+		fn("additional", &ap)
+		p.AdditionalProperties = ap
+	}
+	for i := range p.PrefixItems {
+		fn("item"+strconv.Itoa(i), &p.PrefixItems[i])
+	}
+	if p.Items != nil {
+		fn("item", &p.Items)
+	}
+}
+
+// countStructures count the occurrences of every object structure.
+// the repeated occurrence is not walked into, its children are counted by the first occurrence.
+func (p *property) countStructures(counts map[string]int) {
+	p.eachSubProperty(func(name string, slot **property) {
+		sub := *slot
+		if key := sub.structureKey(); key != "" {
+			counts[key]++
+			if counts[key] > 1 {
+				return
+			}
+		}
+		sub.countStructures(counts)
+	})
+}
+
+// hoistStructures move the repeated structures to defs and replace them by $ref
+func (p *property) hoistStructures(counts map[string]int, hoisted map[string]*property, defs map[string]*property) {
+	p.eachSubProperty(func(name string, slot **property) {
+		sub := *slot
+		key := sub.structureKey()
+		if key == "" || counts[key] < 2 {
+			sub.hoistStructures(counts, hoisted, defs)
+			return
+		}
+		if def, ok := hoisted[key]; ok {
+			def.mergeExamples(sub)
+			*slot = &property{Ref: def}
+			return
+		}
+
+		defName := uniqueDefName(name, defs)
+		sub.Location = constDefsPrefix + defName
+		defs[defName] = sub
+		hoisted[key] = sub
+		*slot = &property{Ref: sub}
+		sub.hoistStructures(counts, hoisted, defs)
+	})
+}
+
+// structureKey the canonical text of object keywords, the examples are ignored and merged on hoisting.
+// returns empty when the property is not an object with properties.
+func (p *property) structureKey() string {
+	if p.Ref != nil || p.Type != "object" || len(p.Properties) == 0 {
+		return ""
+	}
+	om := p.toOrderedMap(latest)
+	om.remove("examples")
+	b, err := json.Marshal(om)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// mergeExamples add the examples of the occurrence of the same structure into p and its sub properties
+func (p *property) mergeExamples(occurrence *property) {
+	for _, example := range occurrence.Examples {
+		if !containsValue(p.Examples, example) {
+			p.Examples = append(p.Examples, example)
+		}
+	}
+	var subs []*property
+	occurrence.eachSubProperty(func(name string, slot **property) {
+		subs = append(subs, *slot)
+	})
+	i := 0
+	p.eachSubProperty(func(name string, slot **property) {
+		if i < len(subs) {
+			target, sub := *slot, subs[i]
+			if target.Ref != nil {
+				target = target.Ref
+			}
+			if sub.Ref != nil {
+				sub = sub.Ref
+			}
+			if target != sub {
+				target.mergeExamples(sub)
+			}
+		}
+		i++
+	})
+}
+
+// remove delete the annotation keywords of the schema and all of its sub schemas
+func (o *orderedMap) remove(keys ...string) {
+	for _, key := range keys {
+		if _, ok := o.values[key]; !ok {
+			continue
+		}
+		delete(o.values, key)
+		for i, k := range o.keys {
+			if k == key {
+				o.keys = append(o.keys[:i], o.keys[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, value := range o.values {
+		switch vv := value.(type) {
+		case *orderedMap:
+			vv.remove(keys...)
+		case []interface{}:
+			for _, sub := range vv {
+				if om, ok := sub.(*orderedMap); ok {
+					om.remove(keys...)
+				}
+			}
+		case map[string]interface{}:
+			for _, sub := range vv {
+				if om, ok := sub.(*orderedMap); ok {
+					om.remove(keys...)
+				}
+			}
+		}
+	}
+}
+
+// refLocation spell the $ref of the def by the draft
+func refLocation(location string, draft *Draft) string {
+	if draft.version < 2019 && strings.HasPrefix(location, constDefsPrefix) {
+		return "#/definitions/" + strings.TrimPrefix(location, constDefsPrefix)
+	}
+	return location
+}
+
+func uniqueDefName(name string, defs map[string]*property) string {
+	if name == "" {
+		name = "Def"
+	}
+	name = replaceName(name)
+	defName := name
+	for i := 2; ; i++ {
+		if _, ok := defs[defName]; !ok {
+			return defName
+		}
+		defName = name + strconv.Itoa(i)
+	}
+}
+
+func sortedPropertyKeys(m map[string]*property) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyProperty a deep copy of p, the $ref in the copy link to the same defs as p.
+// returns error when p can not be copied, p is never returned that the copy does not alias the tree
+func (p *property) copyProperty() (*property, error) {
+	refs := make(map[string]*property)
+	p.walk(func(sub *property) {
+		if sub.Ref != nil {
+			refs[sub.Ref.Location] = sub.Ref
+		}
+	})
+	c := &property{}
+	raw, err := json.Marshal(p.toValue(latest))
+	if err != nil {
+		return nil, fmt.Errorf("copy schema: %v", err)
+	}
+	if err := c.fromValue(raw, latest); err != nil {
+		return nil, fmt.Errorf("copy schema: %v", err)
+	}
+	c.walk(func(sub *property) {
+		if sub.Ref == nil {
+			return
+		}
+		if def, ok := refs[sub.Ref.Location]; ok {
+			sub.Ref = def
+		}
+	})
+	return c, nil
+}
+
+// unhoisted a copy of p whose $ref are replaced by the copies of their defs, the recursive $ref is kept.
+// kept collects the kept $ref
+func (p *property) unhoisted(stack []*property, kept map[*property]bool) (*property, error) {
+	c, err := p.copyProperty()
+	if err != nil {
+		return nil, err
+	}
+	c.walk(func(sub *property) {
+		if err != nil || sub.Ref == nil || kept[sub] {
+			return
+		}
+		def := sub.Ref
+		for _, s := range stack {
+			if s == def {
+				kept[sub] = true
+				return
+			}
+		}
+		var inlined *property
+		if inlined, err = def.unhoisted(append(stack[:len(stack):len(stack)], def), kept); err == nil {
+			*sub = *inlined
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// pruneDefs remove the defs that are not referenced from the schema any more
+func (d *SchemaDocument) pruneDefs() {
+	if len(d.Defs) == 0 {
+		return
+	}
+	defs := d.Defs
+	d.Defs = nil
+	used := make(map[*property]bool)
+	var mark func(p *property)
+	mark = func(p *property) {
+		p.walk(func(sub *property) {
+			if sub.Ref != nil && !used[sub.Ref] {
+				used[sub.Ref] = true
+				mark(sub.Ref)
+			}
+		})
+	}
+	mark(&d.property)
+	for name, def := range defs {
+		if used[def] {
+			if d.Defs == nil {
+				d.Defs = make(map[string]*property)
+			}
+			d.Defs[name] = def
+		}
+	}
+}
+
+// rehoistDefs inline the defs, and extract the repeated structures again. the recursive defs are kept.
+// d is not changed when it can not be inlined
+func (d *SchemaDocument) rehoistDefs() error {
+	defs := d.Defs
+	inlined, err := d.property.unhoisted(nil, make(map[*property]bool))
+	if err != nil {
+		return err
+	}
+	inlined.Defs = defs
+	d.property = *inlined
+	d.pruneDefs()
+	d.ExtractDefs()
+	return nil
+}
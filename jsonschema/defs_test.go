@@ -0,0 +1,117 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_ExtractDefs(t *testing.T) {
+	data := []byte(`{
+		"buyer": {"name": "ab", "age": 3},
+		"seller": {"name": "ab", "age": 3},
+		"orders": [{"owner": {"name": "ab", "age": 3}, "amount": 5}],
+		"other": {"title": "x"}
+	}`)
+
+	inline, err := GenerateSchemaDataModel(data, "defs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reuse := NewSchemaDataModel(data, "defs")
+	reuse.Reuse = true
+	if err := reuse.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reuse.Document.Defs) != 1 {
+		t.Fatalf("expect 1 def, got %d", len(reuse.Document.Defs))
+	}
+	text, _ := reuse.Document.String()
+	if strings.Count(text, `"$ref": "#/$defs/Buyer"`) != 3 {
+		t.Errorf("expect 3 $ref in %s", text)
+	}
+
+	inlineText, _ := inline.Document.String()
+	inlineSchema := MustCompileString("inline.json", inlineText)
+	reuseSchema := MustCompileString("reuse.json", text)
+	for _, instance := range []string{
+		string(data),
+		`{"buyer": {"name": "ab", "age": 3}, "seller": {"name": "ab"}, "orders": [], "other": {"title": "x"}}`,
+		`{"buyer": {"name": "ab", "age": 3}, "seller": {"name": "ab", "age": 3}, "orders": [{"owner": {"name": 1, "age": 3}, "amount": 5}], "other": {"title": "x"}}`,
+	} {
+		var v interface{}
+		json.Unmarshal([]byte(instance), &v)
+		if (inlineSchema.Validate(v) == nil) != (reuseSchema.Validate(v) == nil) {
+			t.Errorf("validation differs for %s", instance)
+		}
+	}
+
+	_, draft7 := readDraft(7)
+	reuse.Document.Schema = draft7
+	text, _ = reuse.Document.String()
+	if !strings.Contains(text, `"#/definitions/Buyer"`) {
+		t.Errorf("draft 7 should reference definitions: %s", text)
+	}
+	MustCompileString("draft7.json", text)
+}
+
+func Test_MergeDefs(t *testing.T) {
+	reuse := NewSchemaDataModel([]byte(`{"buyer": {"name": "ab", "age": 3}, "seller": {"name": "cd", "age": 3}}`), "defs")
+	reuse.Reuse = true
+	if err := reuse.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	def := reuse.Document.Defs["Buyer"]
+	if def == nil || len(def.Properties["name"].Examples) != 2 {
+		t.Fatalf("the examples of the occurrences should be merged into the def: %+v", reuse.Document.Defs)
+	}
+
+	sample, err := GenerateSchemaDataModel([]byte(`{"buyer": {"name": "ab", "age": 3}, "seller": {"name": "cd", "age": 3, "vip": true}, "agent": {"name": "ef", "age": 3}}`), "defs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reuse.Document.MergeSchemaDocument(sample.Document); err != nil {
+		t.Fatal(err)
+	}
+	buyer, _, _ := reuse.Document.lookup([]string{"buyer", "vip"})
+	seller, _, _ := reuse.Document.lookup([]string{"seller", "vip"})
+	if buyer != nil || seller == nil {
+		t.Errorf("the sample of seller should not widen buyer: %v %v", buyer, seller)
+	}
+	text, _ := reuse.Document.String()
+	if len(reuse.Document.Defs) != 1 || strings.Count(text, `"$ref": "#/$defs/Agent"`) != 2 {
+		t.Errorf("the occurrences of the same structure should be hoisted again: %s", text)
+	}
+
+	// the defs of the sample are inlined
+	sample.Reuse = true
+	sample.Document = &SchemaDocument{Schema: sample.Document.Schema}
+	sample.Data = []byte(`{"buyer": {"name": "ab", "age": 3}, "seller": {"name": "ab", "age": 3}, "broker": {"name": "ab", "age": 3}}`)
+	if err := sample.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reuse.Document.MergeSchemaDocument(sample.Document); err != nil {
+		t.Fatal(err)
+	}
+	if broker, _, _ := reuse.Document.lookup([]string{"broker", "name"}); broker == nil || reuse.Document.Properties["broker"].Ref == nil {
+		t.Errorf("the new field of the def of sample should be hoisted: %+v", reuse.Document.Properties["broker"])
+	}
+	text, _ = reuse.Document.String()
+	MustCompileString("merged.json", text)
+
+	// the def that can not be copied is not merged into, and the occurrences keep referring it
+	agent := reuse.Document.Properties["agent"].Ref
+	agent.Properties["age"].Examples = append(agent.Properties["age"].Examples, math.Inf(1))
+	sample, err = GenerateSchemaDataModel([]byte(`{"agent": {"name": "gh", "age": 4, "vip": true}}`), "defs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reuse.Document.MergeSchemaDocument(sample.Document); err == nil {
+		t.Error("the def that can not be copied is merged")
+	}
+	if reuse.Document.Properties["agent"].Ref != agent || agent.Properties["vip"] != nil {
+		t.Errorf("the def is changed by the failed merge %+v", agent)
+	}
+}
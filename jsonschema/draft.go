@@ -1,6 +1,7 @@
 package jsonschema
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -169,7 +170,7 @@ func readDraft(version int) (*Draft, string) {
 	case 7:
 		return Draft7, "https://json-schema.org/draft-07/schema"
 	case 2019:
-		return Draft2019, "https://json-schema.org/draft-07/schema"
+		return Draft2019, "https://json-schema.org/draft/2019-09/schema"
 	case 2020:
 		return Draft2020, "https://json-schema.org/draft/2020-12/schema"
 	default:
@@ -177,6 +178,24 @@ func readDraft(version int) (*Draft, string) {
 	}
 }
 
+// DraftVersion parse draft name such as 4, draft-07, 2019-09, 2020-12 to draft version
+func DraftVersion(name string) (int, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), "draft")
+	name = strings.TrimLeft(name, "-")
+	if idx := strings.IndexByte(name, '-'); idx != -1 {
+		name = name[:idx]
+	}
+	version, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown draft %q", name)
+	}
+	switch version {
+	case 4, 6, 7, 2019, 2020:
+		return version, nil
+	}
+	return 0, fmt.Errorf("unsupported draft %d", version)
+}
+
 func findDraft(url string) *Draft {
 	if strings.HasPrefix(url, "http://") {
 		url = "https://" + strings.TrimPrefix(url, "http://")
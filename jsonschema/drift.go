@@ -0,0 +1,186 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// drift kinds of DriftEvent
+const (
+	DriftNewField         = "new-field"         // the field is seen for the first time
+	DriftFieldDisappeared = "field-disappeared" // the field is absent from the latest samples in a row
+	DriftTypeWidened      = "type-widened"      // the type accepts more, such as integer to number
+	DriftTypeConflict     = "type-conflict"     // the sample has a type that the schema can not merge
+	DriftFormatLost       = "format-lost"       // a sample does not satisfy the format
+)
+
+// DefaultDriftAbsentSamples the count of samples in a row without a field that makes it disappeared
+const DefaultDriftAbsentSamples = 10
+
+// AbsentSamplesKeyword counted the samples in a row without the field in the schema before AbsentSamples,
+// MergeWithDrift moves the counts of the schemas saved before into AbsentSamples
+const AbsentSamplesKeyword = "x-absent-samples"
+
+// AbsentSamples counts the samples in a row without the field by its json pointer,
+// the bookkeeping of MergeWithDrift kept between merges out of the schema
+type AbsentSamples map[string]int
+
+// DriftEvent the change of the schema shape caused by a merged sample
+type DriftEvent struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"` // json pointer of the field, * stands for the items of array
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (e *DriftEvent) String() string {
+	return fmt.Sprintf("%s %s %s -> %s", e.Kind, e.Path, e.Before, e.After)
+}
+
+// MergeWithDrift merge the schema of sample into d as MergeSchemaDocument, and returns the drift events of the merge.
+// a field is disappeared when absentSamples samples in a row do not have it, DefaultDriftAbsentSamples when not positive.
+// absent is the counts of the previous merges, it is updated by the merge
+func (d *SchemaDocument) MergeWithDrift(sample *SchemaDocument, absent AbsentSamples, absentSamples int) ([]*DriftEvent, error) {
+	if absentSamples <= 0 {
+		absentSamples = DefaultDriftAbsentSamples
+	}
+	before := driftNodes(&d.property)
+	for path, node := range before {
+		if count := keywordAbsentCount(node.p); count > 0 {
+			absent[path] = count
+		}
+		delete(node.p.Keywords, AbsentSamplesKeyword)
+	}
+	seen := driftNodes(&sample.property)
+	// the conflicting fields are told by the type-conflict events
+	if err := d.MergeSchemaDocument(sample); err != nil {
+		if _, conflicts := err.(*MergeConflictError); !conflicts {
+			return nil, err
+		}
+	}
+	after := driftNodes(&d.property)
+	for path := range absent {
+		if _, ok := after[path]; !ok {
+			delete(absent, path)
+		}
+	}
+
+	var events []*DriftEvent
+	for _, path := range sortedNodePaths(after) {
+		node := after[path]
+		old, existed := before[path]
+		sampleNode, present := seen[path]
+		switch {
+		case !existed:
+			// the fields of a new object are not told one by one
+			if _, parentExisted := before[node.parent]; parentExisted && node.field {
+				events = append(events, &DriftEvent{Kind: DriftNewField, Path: path, After: node.types})
+			}
+		case old.types != node.types:
+			events = append(events, &DriftEvent{Kind: DriftTypeWidened, Path: path, Before: old.types, After: node.types})
+		case old.format != "" && node.format == "":
+			events = append(events, &DriftEvent{Kind: DriftFormatLost, Path: path, Before: old.format})
+		}
+		if present && existed && !acceptsTypes(node.types, sampleNode.types) {
+			events = append(events, &DriftEvent{Kind: DriftTypeConflict, Path: path, Before: node.types, After: sampleNode.types})
+		}
+
+		if path == "" || !node.field {
+			continue
+		}
+		if _, parentPresent := seen[node.parent]; present || !parentPresent {
+			// the absence is counted only when the object of the field is in the sample
+			if present {
+				delete(absent, path)
+			}
+			continue
+		}
+		absent[path]++
+		if absent[path] == absentSamples {
+			events = append(events, &DriftEvent{Kind: DriftFieldDisappeared, Path: path, Before: strconv.Itoa(absent[path]) + " samples"})
+		}
+	}
+	return events, nil
+}
+
+// driftNode the shape of a property in the schema tree
+type driftNode struct {
+	p      *property
+	parent string // path of the parent object or array
+	field  bool   // property of object
+	types  string // sorted types joined by comma
+	format string
+}
+
+// driftNodes the properties of the schema tree keyed by json pointer, references are not followed
+func driftNodes(root *property) map[string]*driftNode {
+	nodes := make(map[string]*driftNode)
+	var walk func(p *property, path, parent string, field bool)
+	walk = func(p *property, path, parent string, field bool) {
+		if p == nil {
+			return
+		}
+		if _, ok := nodes[path]; ok {
+			return
+		}
+		nodes[path] = &driftNode{p: p, parent: parent, field: field, types: typeSet(p), format: p.Format}
+		for name, child := range p.Properties {
+			walk(child, path+"/"+escapePointer(name), path, true)
+		}
+		walk(p.Items, path+"/*", path, false)
+		for i, item := range p.PrefixItems {
+			walk(item, path+"/"+strconv.Itoa(i), path, false)
+		}
+	}
+	walk(root, "", "", false)
+	return nodes
+}
+
+func sortedNodePaths(nodes map[string]*driftNode) []string {
+	paths := make([]string, 0, len(nodes))
+	for path := range nodes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func typeSet(p *property) string {
+	types := append([]string{}, p.Types...)
+	if p.Type != "" {
+		types = append(types, p.Type)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ",")
+}
+
+// acceptsTypes whether the schema types accept the sample types, number accepts integer
+func acceptsTypes(schemaTypes, sampleTypes string) bool {
+	if schemaTypes == "" || sampleTypes == "" || sampleTypes == "null" {
+		return true
+	}
+	accepted := strings.Split(schemaTypes, ",")
+	for _, t := range strings.Split(sampleTypes, ",") {
+		if !contains(accepted, t) && !(t == "integer" && contains(accepted, "number")) {
+			return false
+		}
+	}
+	return true
+}
+
+// keywordAbsentCount the count of AbsentSamplesKeyword in the schema saved before AbsentSamples
+func keywordAbsentCount(p *property) int {
+	switch v := p.Keywords[AbsentSamplesKeyword].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,80 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_MergeWithDrift(t *testing.T) {
+	generate := func(data string) *SchemaDocument {
+		m, err := GenerateSchemaDataModel([]byte(data), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Document
+	}
+	kinds := func(events []*DriftEvent) map[string]string {
+		res := make(map[string]string)
+		for _, e := range events {
+			res[e.Path] = e.Kind
+		}
+		return res
+	}
+
+	d := generate(`{"id": 1, "day": "2022-02-22", "code": "A1", "buyer": {"name": "joe"}}`)
+	absent := make(AbsentSamples)
+	events, err := d.MergeWithDrift(generate(`{"id": 1.5, "day": "soon", "code": 7, "buyer": {"name": "joe", "vip": true}, "tags": {"a": "b"}}`), absent, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"/id":        DriftTypeWidened,
+		"/day":       DriftFormatLost,
+		"/code":      DriftTypeConflict,
+		"/buyer/vip": DriftNewField,
+		"/tags":      DriftNewField,
+	}
+	if got := kinds(events); len(got) != len(expected) {
+		t.Errorf("unexpected events %v", events)
+	} else {
+		for path, kind := range expected {
+			if got[path] != kind {
+				t.Errorf("%s: expected %s, got %s", path, kind, got[path])
+			}
+		}
+	}
+
+	// the absence is counted out of the stored schema
+	for i := 1; i <= 4; i++ {
+		text, _ := json.Marshal(d)
+		if strings.Contains(string(text), AbsentSamplesKeyword) {
+			t.Fatalf("the absence is in the schema %s", text)
+		}
+		d = &SchemaDocument{}
+		json.Unmarshal(text, d)
+		events, err = d.MergeWithDrift(generate(`{"id": 2, "day": "x", "code": "B2", "buyer": {"name": "ann"}}`), absent, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := kinds(events)
+		if disappeared := got["/tags"] == DriftFieldDisappeared; disappeared != (i == 3) {
+			t.Errorf("sample %d: tags disappeared %v %v", i, disappeared, events)
+		}
+		if _, ok := got["/tags/a"]; ok {
+			t.Errorf("the absence of the fields of absent object is not counted %v", events)
+		}
+	}
+
+	events, _ = d.MergeWithDrift(generate(`{"id": 2, "day": "x", "code": "B2", "buyer": {"name": "ann"}, "tags": {}}`), absent, 3)
+	if len(events) != 0 || absent["/tags"] != 0 {
+		t.Errorf("the absence is reset by the present field %v %v", events, absent)
+	}
+
+	// the counts in the schema saved before are moved out
+	d.Properties["buyer"].Keywords = map[string]interface{}{AbsentSamplesKeyword: json.Number("2")}
+	events, _ = d.MergeWithDrift(generate(`{"id": 2}`), absent, 3)
+	if d.Properties["buyer"].Keywords[AbsentSamplesKeyword] != nil || absent["/buyer"] != 3 || kinds(events)["/buyer"] != DriftFieldDisappeared {
+		t.Errorf("the saved absence is not moved %v %v", absent, events)
+	}
+}
@@ -0,0 +1,104 @@
+package jsonschema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatDetectors tell whether a sample string is of the format during inference, keyed by format name.
+// they are stricter than the validators of Formats, which accept almost any text for some formats.
+var FormatDetectors = map[string]func(string) bool{
+	"date":                  func(s string) bool { return isDate(s) },
+	"date-time":             func(s string) bool { return isDateTime(s) },
+	"time":                  func(s string) bool { return isTime(s) },
+	"uuid":                  func(s string) bool { return isUUID(s) },
+	"duration":              func(s string) bool { return isDuration(s) },
+	"ipv4":                  func(s string) bool { return isIPV4(s) },
+	"ipv6":                  func(s string) bool { return strings.Contains(s, ":") && isIPV6(s) },
+	"email":                 func(s string) bool { return isEmail(s) },
+	"hostname":              isDomainName,
+	"uri":                   isAbsoluteURI,
+	"uri-reference":         func(s string) bool { return isURIReference(s) },
+	"json-pointer":          func(s string) bool { return strings.HasPrefix(s, "/") && isJSONPointer(s) },
+	"relative-json-pointer": func(s string) bool { return isRelativeJSONPointer(s) },
+	"regex":                 func(s string) bool { return isRegex(s) },
+}
+
+// DefaultDetectFormats the formats detected by default, in order. the first matched format wins.
+// uri-reference, json-pointer, relative-json-pointer and regex match ordinary text, so they are opt-in.
+var DefaultDetectFormats = []string{
+	"date", "date-time", "time", "uuid", "duration", "ipv4", "ipv6", "email", "hostname", "uri",
+}
+
+// CheckDetectFormats returns error when any format has no detector
+func CheckDetectFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := FormatDetectors[format]; !ok {
+			return fmt.Errorf("unsupported format %q", format)
+		}
+	}
+	return nil
+}
+
+// detectFormat returns the first format of formats that value satisfies, empty when none.
+func detectFormat(value string, formats []string) string {
+	if value == "" {
+		return ""
+	}
+	if formats == nil {
+		formats = DefaultDetectFormats
+	}
+	for _, format := range formats {
+		if detect, ok := FormatDetectors[format]; ok && detect(value) {
+			return format
+		}
+	}
+	return ""
+}
+
+// isAbsoluteURI uri with both scheme and host, such as https://example.com/path
+func isAbsoluteURI(value string) bool {
+	u, err := urlParse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// isDomainName hostname with at least two labels and a known top level label, such as api.example.com.
+// a single label hostname can not be told from a plain word, and the names whose top level label is unknown,
+// such as john.doe, file.txt or v1.2, from the dotted text. the label before the top level one is not all digits
+func isDomainName(value string) bool {
+	labels := strings.Split(value, ".")
+	if len(labels) < 2 || !isHostname(value) {
+		return false
+	}
+	if !topLevelDomains[strings.ToLower(labels[len(labels)-1])] {
+		return false
+	}
+	for _, label := range labels[:len(labels)-1] {
+		if strings.Trim(label, "0123456789") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelDomains the generic and country code top level domains detected as hostname.
+// the country codes that are common file extensions, such as md, sh, py, pl, rs, so and ps, are left out
+var topLevelDomains = func() map[string]bool {
+	names := strings.Fields(`
+		com org net edu gov mil int arpa info biz name pro aero coop museum mobi asia tel travel jobs cat post
+		app dev io ai co me tv cc ly gg fm sg xyz online site tech store cloud shop blog news live world today
+		global group team work email link click page space website digital network systems solutions services
+		company center agency media studio design land life plus top vip club wiki local internal localhost test example
+		ac ad ae af ag al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bm bn bo br bs bt bw by bz
+		ca cd cf cg ch ci ck cl cm cn cr cu cv cw cx cy cz de dj dk dm do dz ec ee eg er es et eu fi fj fk fo fr
+		ga gb gd ge gf gh gi gl gm gn gp gq gr gs gt gu gw gy hk hm hn hr ht hu id ie il im in iq ir is it je jm
+		jo jp ke kg kh ki km kn kp kr kw ky kz la lb lc li lk lr ls lt lu lv ma mc mg mh mk ml mm mn mo mp mq
+		mr mt mu mv mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pm pn pr pt pw qa re ro
+		ru rw sa sb sc sd se si sk sl sm sn sr ss st sv sx sy sz tc td tf tg th tj tk tl tm tn to tr tt tw tz
+		ua ug uk us uy uz va vc ve vg vi vn vu wf ws ye yt za zm zw`)
+	domains := make(map[string]bool, len(names))
+	for _, name := range names {
+		domains[name] = true
+	}
+	return domains
+}()
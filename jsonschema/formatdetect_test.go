@@ -0,0 +1,64 @@
+package jsonschema
+
+import "testing"
+
+func Test_DetectFormat(t *testing.T) {
+	cases := map[string]string{
+		"hello world":             "",
+		"abc":                     "",
+		"/api/orders":             "",
+		"a+b":                     "",
+		"2022-10-01":              "date",
+		"2022-10-01T10:20:30Z":    "date-time",
+		"https://example.com/a?b": "uri",
+		"mailto:a@example.com":    "",
+		"a@example.com":           "email",
+		"Joe <a@example.com>":     "",
+		"10.0.0.1":                "ipv4",
+		"::1":                     "ipv6",
+		"api.example.com":         "hostname",
+		"cdn.shop.co.uk":          "hostname",
+		"john.doe":                "",
+		"file.txt":                "",
+		"README.md":               "",
+		"v1.2":                    "",
+		"1.2.3.com":               "",
+	}
+	for value, expected := range cases {
+		if format := detectFormat(value, nil); format != expected {
+			t.Errorf("%q: expected %q, got %q", value, expected, format)
+		}
+	}
+
+	if format := detectFormat("a+b", []string{"uuid", "regex"}); format != "regex" {
+		t.Errorf("regex should be detected when it is configured, got %q", format)
+	}
+	if err := CheckDetectFormats([]string{"date", "url"}); err == nil {
+		t.Error("url has no detector")
+	}
+}
+
+func Test_MergeFormatAgreement(t *testing.T) {
+	m := NewSchemaDataModel(nil, "")
+	merge := func(values ...string) *property {
+		x := &property{}
+		m.parseString(values[0], "", x)
+		for _, v := range values[1:] {
+			y := &property{}
+			m.parseString(v, "", y)
+			if err := mergeProperty(x, y); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return x
+	}
+	if p := merge("2022-10-01", "2022-11-30"); p.Format != "date" {
+		t.Errorf("expected date, got %q", p.Format)
+	}
+	if p := merge("2022-10-01", "tomorrow"); p.Format != "" {
+		t.Errorf("format should be dropped, got %q", p.Format)
+	}
+	if p := merge("tomorrow", "2022-10-01"); p.Format != "" {
+		t.Errorf("format should not be adopted, got %q", p.Format)
+	}
+}
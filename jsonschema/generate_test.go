@@ -0,0 +1,140 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_GenerateInteger(t *testing.T) {
+	m, err := GenerateSchemaDataModel([]byte(`{"id": 9007199254740993, "count": 3, "price": 1.5, "items": [{"qty": 1}]}`), "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := m.Document.Properties
+	if props["id"].Type != "integer" || props["id"].Maximum != "9007199254740993" {
+		t.Errorf("id: unexpected %s %s", props["id"].Type, props["id"].Maximum)
+	}
+	if props["count"].Type != "integer" || props["price"].Type != "number" {
+		t.Errorf("unexpected types count %s price %s", props["count"].Type, props["price"].Type)
+	}
+
+	y, err := GenerateSchemaDataModel([]byte(`{"id": 1, "count": 2.5, "price": 2, "items": [{"qty": 0.5}, {"qty": 2}]}`), "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Document.MergeSchemaDocument(y.Document); err != nil {
+		t.Fatal(err)
+	}
+	if props["id"].Type != "integer" || props["id"].Minimum != "1" || props["id"].Maximum != "9007199254740993" {
+		t.Errorf("id: unexpected %s [%s, %s]", props["id"].Type, props["id"].Minimum, props["id"].Maximum)
+	}
+	if props["count"].Type != "number" || props["price"].Type != "number" {
+		t.Errorf("unexpected types count %s price %s", props["count"].Type, props["price"].Type)
+	}
+	if qty := props["items"].Items.Properties["qty"]; qty.Type != "number" || qty.Minimum != "0.5" {
+		t.Errorf("nested qty: unexpected %s %s", qty.Type, qty.Minimum)
+	}
+}
+
+func Test_GenerateArray(t *testing.T) {
+	m, err := GenerateSchemaDataModel([]byte(`{
+		"orders": [{"id": 1}, {"id": 2, "note": "gift"}],
+		"tags": ["a", "b"],
+		"codes": ["x"],
+		"point": ["p1", 3.5, true],
+		"mixed": [1, "a", 2, "b", 3, "c", 4, "d", 5]
+	}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := m.Document.Properties
+
+	orders := props["orders"]
+	if orders.Items == nil || orders.Items.Properties["note"] == nil {
+		t.Fatalf("orders: later elements are not merged")
+	}
+	if contains(orders.Items.Required, "note") || !contains(orders.Items.Required, "id") {
+		t.Errorf("orders: unexpected required %v", orders.Items.Required)
+	}
+	if orders.MaxItems != nil || orders.UniqueItems {
+		t.Errorf("orders: unexpected bounds %v %v", orders.MaxItems, orders.UniqueItems)
+	}
+
+	if !props["tags"].UniqueItems {
+		t.Error("tags: should be unique")
+	}
+	if props["codes"].UniqueItems {
+		t.Error("codes: the single element is inferred unique")
+	}
+
+	point := props["point"]
+	if len(point.PrefixItems) != 3 || point.PrefixItems[1].Type != "number" || point.Items.Always == nil {
+		t.Errorf("point: tuple is not detected")
+	}
+
+	if mixed := props["mixed"].Items; len(mixed.Types) != 2 || mixed.Types[0] != "integer" || mixed.Types[1] != "string" {
+		t.Errorf("mixed: unexpected items %v", mixed.Types)
+	}
+
+	y, err := GenerateSchemaDataModel([]byte(`{"orders": [], "tags": ["a", "a"], "point": ["p2", 1, false], "mixed": []}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Document.MergeSchemaDocument(y.Document); err != nil {
+		t.Fatal(err)
+	}
+	if orders.MinItems != nil || props["tags"].UniqueItems || len(point.PrefixItems) != 3 {
+		t.Errorf("unexpected merged arrays: %v %v %v", orders.MinItems, props["tags"].UniqueItems, len(point.PrefixItems))
+	}
+
+	text, err := m.Document.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := CompileString("array.json", string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	json.Unmarshal([]byte(`{"orders": [{"id": 2}], "tags": ["z"], "point": ["p3", 2, true], "mixed": ["x", 7]}`), &v)
+	if err := schema.Validate(v); err != nil {
+		t.Error(err)
+	}
+	json.Unmarshal([]byte(`{"orders": [], "tags": [], "point": ["p3", 2], "mixed": []}`), &v)
+	if err := schema.Validate(v); err == nil {
+		t.Error("short tuple should be invalid")
+	}
+}
+
+func Test_MergeConflicts(t *testing.T) {
+	m, err := GenerateSchemaDataModel([]byte(`{"id": 1, "buyer": {"name": "joe", "age": 30}, "lines": [{"sku": "a"}], "note": "x"}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := GenerateSchemaDataModel([]byte(`{"id": "A1", "buyer": {"name": "ann", "age": "old"}, "lines": [{"sku": 2}], "note": "y", "extra": true}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Document.MergeSchemaDocument(y.Document)
+	conflicts, ok := err.(*MergeConflictError)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"/buyer/age: Type difference integer vs string",
+		"/id: Type difference integer vs string",
+		"/lines/*/sku: Type difference string vs integer",
+	}
+	if len(conflicts.Conflicts) != len(expected) {
+		t.Fatalf("unexpected conflicts %v", conflicts.Conflicts)
+	}
+	for i := range expected {
+		if conflicts.Conflicts[i] != expected[i] {
+			t.Errorf("conflict %d: %q, expected %q", i, conflicts.Conflicts[i], expected[i])
+		}
+	}
+	// the other fields are still merged
+	if props := m.Document.Properties; props["extra"] == nil || props["id"].Type != "integer" {
+		t.Errorf("the sample is not merged beside the conflicts")
+	}
+}
@@ -84,6 +84,15 @@ func ParseJson(b []byte) (interface{}, error) {
 	return f, err
 }
 
+// ParseJsonNumber parse json and keep numbers as json.Number, so integers and big ids keep their precision
+func ParseJsonNumber(b []byte) (interface{}, error) {
+	var f interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&f)
+	return f, err
+}
+
 func PrintGo(f interface{}, name string) {
 	WriteGo(os.Stdout, f, name)
 }
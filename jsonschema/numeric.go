@@ -0,0 +1,149 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// numeric inference modes of SchemaDataModel
+const (
+	NumericExact = "exact" // minimum and maximum are the observed values
+	NumericRange = "range" // the observed values padded by NumericPadding of their magnitude
+	NumericNone  = "none"  // no minimum and maximum
+)
+
+// DefaultNumericPadding padding ratio of NumericRange when NumericPadding is not set
+const DefaultNumericPadding = 0.5
+
+// PercentilesKeyword the statistics of the number samples, an annotation that is not validated
+const PercentilesKeyword = "x-percentiles"
+
+// constNumberMaxExamples the number samples kept as examples, the percentiles are of the latest samples
+const constNumberMaxExamples = 1000
+
+// CheckNumericMode returns error when the numeric inference mode is unknown
+func CheckNumericMode(mode string) error {
+	switch mode {
+	case "", NumericExact, NumericRange, NumericNone:
+		return nil
+	}
+	return fmt.Errorf("unsupported numeric mode %q", mode)
+}
+
+// inferNumber set the numeric constraints of the observed value by the numeric options of model
+func (m *SchemaDataModel) inferNumber(vv json.Number, integer bool, p *property) {
+	switch m.NumericMode {
+	case NumericNone:
+	case NumericRange:
+		padding := m.NumericPadding
+		if padding <= 0 {
+			padding = DefaultNumericPadding
+		}
+		p.Minimum, p.Maximum = paddedRange(vv, padding, integer)
+	default:
+		p.Minimum, p.Maximum = vv, vv
+	}
+	if m.MultipleOf {
+		p.MultipleOf = decimalStep(vv)
+	}
+	if m.Percentiles {
+		p.Examples = append(p.Examples, vv)
+		p.setPercentiles()
+	}
+}
+
+// paddedRange widen the value by padding ratio of its magnitude, the bounds of integer are rounded outwards.
+// zero is widened by the padding ratio of one, so it is a range as the other values.
+// the padding depends on the value only, so merging padded ranges never pads twice.
+func paddedRange(vv json.Number, padding float64, integer bool) (json.Number, json.Number) {
+	v, ok := new(big.Rat).SetString(string(vv))
+	if !ok {
+		return vv, vv
+	}
+	pad := new(big.Rat).Abs(v)
+	if pad.Sign() == 0 {
+		pad.SetInt64(1)
+	}
+	pad.Mul(pad, new(big.Rat).SetFloat64(padding))
+	min := new(big.Rat).Sub(v, pad)
+	max := new(big.Rat).Add(v, pad)
+	return ratNumber(min, integer, false), ratNumber(max, integer, true)
+}
+
+func ratNumber(r *big.Rat, integer bool, ceil bool) json.Number {
+	if !integer {
+		f, _ := r.Float64()
+		return floatNumber(f)
+	}
+	q, mod := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if ceil && mod.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return json.Number(q.String())
+}
+
+// decimalStep the decimal step of value as multipleOf, such as 12.34 to 0.01, 1500 to 100 and 0 to 1.
+// returns empty for the exponent notation.
+func decimalStep(vv json.Number) json.Number {
+	text := strings.TrimLeft(string(vv), "-")
+	if strings.ContainsAny(text, "eE") {
+		return ""
+	}
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		if decimals := len(strings.TrimRight(text[dot+1:], "0")); decimals > 0 {
+			return json.Number("0." + strings.Repeat("0", decimals-1) + "1")
+		}
+		text = text[:dot]
+	}
+	zeros := len(text) - len(strings.TrimRight(text, "0"))
+	if zeros == len(text) {
+		return "1"
+	}
+	return json.Number("1" + strings.Repeat("0", zeros))
+}
+
+// limitNumberExamples drop the oldest number examples beyond constNumberMaxExamples
+func (p *property) limitNumberExamples() {
+	if over := len(p.Examples) - constNumberMaxExamples; over > 0 {
+		p.Examples = append(p.Examples[:0:0], p.Examples[over:]...)
+	}
+}
+
+// setPercentiles annotate the p50, p90, p99 of the number examples
+func (p *property) setPercentiles() {
+	values := make([]float64, 0, len(p.Examples))
+	for _, example := range p.Examples {
+		switch v := example.(type) {
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				values = append(values, f)
+			}
+		case float64:
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	sort.Float64s(values)
+	rank := func(percent float64) json.Number {
+		// nearest rank method
+		i := int(math.Ceil(percent/100*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return floatNumber(values[i])
+	}
+	if p.Keywords == nil {
+		p.Keywords = make(map[string]interface{})
+	}
+	p.Keywords[PercentilesKeyword] = map[string]interface{}{
+		"p50": rank(50),
+		"p90": rank(90),
+		"p99": rank(99),
+	}
+}
@@ -0,0 +1,90 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_NumericMode(t *testing.T) {
+	data := []byte(`{"count": 100, "price": -2.5, "stock": 0}`)
+	cases := []struct {
+		mode               string
+		countMin, countMax json.Number
+		priceMin, priceMax json.Number
+		stockMin, stockMax json.Number
+	}{
+		{NumericExact, "100", "100", "-2.5", "-2.5", "0", "0"},
+		{NumericRange, "50", "150", "-3.75", "-1.25", "-1", "1"},
+		{NumericNone, "", "", "", "", "", ""},
+	}
+	for _, c := range cases {
+		m := NewSchemaDataModel(data, "")
+		m.NumericMode = c.mode
+		if err := m.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		count, price := m.Document.Properties["count"], m.Document.Properties["price"]
+		if count.Minimum != c.countMin || count.Maximum != c.countMax {
+			t.Errorf("%s count: unexpected [%s, %s]", c.mode, count.Minimum, count.Maximum)
+		}
+		if price.Minimum != c.priceMin || price.Maximum != c.priceMax {
+			t.Errorf("%s price: unexpected [%s, %s]", c.mode, price.Minimum, price.Maximum)
+		}
+		if stock := m.Document.Properties["stock"]; stock.Minimum != c.stockMin || stock.Maximum != c.stockMax {
+			t.Errorf("%s stock: unexpected [%s, %s]", c.mode, stock.Minimum, stock.Maximum)
+		}
+	}
+	if err := CheckNumericMode("padded"); err == nil {
+		t.Error("padded is not a numeric mode")
+	}
+}
+
+func Test_MultipleOfAndPercentiles(t *testing.T) {
+	generate := func(data string) *SchemaDocument {
+		m := NewSchemaDataModel([]byte(data), "")
+		m.MultipleOf = true
+		m.Percentiles = true
+		if err := m.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		return m.Document
+	}
+	doc := generate(`{"amount": 12.5, "total": 1500}`)
+	for _, v := range []string{`{"amount": 7.25, "total": 300}`, `{"amount": 3, "total": 20}`} {
+		if err := doc.MergeSchemaDocument(generate(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	amount, total := doc.Properties["amount"], doc.Properties["total"]
+	if amount.MultipleOf != "0.01" {
+		t.Errorf("amount: unexpected multipleOf %s", amount.MultipleOf)
+	}
+	if total.MultipleOf != "10" || doc.Properties["amount"].Type != "number" {
+		t.Errorf("total: unexpected multipleOf %s", total.MultipleOf)
+	}
+	percentiles, _ := total.Keywords[PercentilesKeyword].(map[string]interface{})
+	if percentiles["p50"] != json.Number("300") || percentiles["p99"] != json.Number("1500") {
+		t.Errorf("total: unexpected percentiles %v", percentiles)
+	}
+
+	for i := 0; i < constNumberMaxExamples; i++ {
+		if err := doc.MergeSchemaDocument(generate(`{"amount": 1, "total": 10}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(total.Examples) != constNumberMaxExamples || total.Examples[0] != json.Number("10") {
+		t.Errorf("total: %d examples are kept", len(total.Examples))
+	}
+
+	text, err := doc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := CompileString("numeric.json", string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(map[string]interface{}{"amount": 7.26, "total": 40}); err != nil {
+		t.Error(err)
+	}
+}
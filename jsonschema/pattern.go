@@ -0,0 +1,272 @@
+package jsonschema
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	constPatternMaxLength = 64 // longer strings are free text, no pattern is learned
+	constPatternHexLength = 8  // the shortest run of hex digits that is taken as one hex token
+	constPatternMetaChars = `\.+*?()|[]{}^$/`
+)
+
+// patternToken one position of the generalized pattern,
+// it is a literal character or a run of character classes with length bounds.
+type patternToken struct {
+	literal byte // 0 when the token is a character class
+	digit   bool
+	upper   bool
+	lower   bool
+	hex     bool // letters are limited to a-f/A-F
+	min     int
+	max     int
+}
+
+// HasPattern whether a property of the schema has a pattern, the samples merged into it are learned with patterns
+func (d *SchemaDocument) HasPattern() bool {
+	has := false
+	d.property.walk(func(p *property) {
+		has = has || p.Pattern != ""
+	})
+	return has
+}
+
+// inferPattern generalize the string to a regex pattern of character classes,
+// such as CN12345678 to ^[A-Z]{2}[0-9]{8}$. returns empty when the string is not a short token.
+func inferPattern(s string) string {
+	if s == "" || len(s) > constPatternMaxLength {
+		return ""
+	}
+	var tokens []patternToken
+	alnum := false
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x80 || c < 0x20 {
+			return ""
+		}
+		if !isAlnum(c) {
+			tokens = append(tokens, patternToken{literal: c})
+			i++
+			continue
+		}
+		alnum = true
+		j := i
+		for j < len(s) && isAlnum(s[j]) {
+			j++
+		}
+		tokens = append(tokens, alnumTokens(s[i:j])...)
+		i = j
+	}
+	if !alnum {
+		return ""
+	}
+	return renderPattern(tokens)
+}
+
+// alnumTokens split the run of letters and digits by character class, the long hex run is kept as one token
+func alnumTokens(run string) []patternToken {
+	if hex := hexToken(run); hex != nil {
+		return []patternToken{*hex}
+	}
+	var tokens []patternToken
+	for i := 0; i < len(run); {
+		t := patternToken{}
+		j := i
+		for j < len(run) && isDigit(run[j]) == isDigit(run[i]) {
+			t.digit = t.digit || isDigit(run[j])
+			t.upper = t.upper || (run[j] >= 'A' && run[j] <= 'Z')
+			t.lower = t.lower || (run[j] >= 'a' && run[j] <= 'z')
+			j++
+		}
+		t.min, t.max = j-i, j-i
+		tokens = append(tokens, t)
+		i = j
+	}
+	return tokens
+}
+
+func hexToken(run string) *patternToken {
+	if len(run) < constPatternHexLength {
+		return nil
+	}
+	t := &patternToken{hex: true, min: len(run), max: len(run)}
+	for i := 0; i < len(run); i++ {
+		c := run[i]
+		switch {
+		case isDigit(c):
+			t.digit = true
+		case c >= 'a' && c <= 'f':
+			t.lower = true
+		case c >= 'A' && c <= 'F':
+			t.upper = true
+		default:
+			return nil
+		}
+	}
+	if !t.digit || t.upper == t.lower {
+		return nil
+	}
+	return t
+}
+
+// mergePattern widen the pattern a and b to one pattern that matches both.
+// returns empty when they have different shapes.
+func mergePattern(a, b string) string {
+	if a == "" || b == "" {
+		return ""
+	}
+	if a == b {
+		return a
+	}
+	x, ok := parsePattern(a)
+	if !ok {
+		return ""
+	}
+	y, ok := parsePattern(b)
+	if !ok || len(x) != len(y) {
+		return ""
+	}
+	for i := range x {
+		if (x[i].literal == 0) != (y[i].literal == 0) {
+			return ""
+		}
+		if x[i].literal != 0 {
+			if x[i].literal != y[i].literal {
+				return ""
+			}
+			continue
+		}
+		// a run of digits fits both hex and letters
+		hex := (x[i].hex || !x[i].letters()) && (y[i].hex || !y[i].letters())
+		x[i].digit = x[i].digit || y[i].digit
+		x[i].upper = x[i].upper || y[i].upper
+		x[i].lower = x[i].lower || y[i].lower
+		x[i].hex = hex && x[i].letters()
+		if y[i].min < x[i].min {
+			x[i].min = y[i].min
+		}
+		if y[i].max > x[i].max {
+			x[i].max = y[i].max
+		}
+	}
+	return renderPattern(x)
+}
+
+func (t patternToken) letters() bool {
+	return t.upper || t.lower
+}
+
+func renderPattern(tokens []patternToken) string {
+	var sb strings.Builder
+	sb.WriteByte('^')
+	for _, t := range tokens {
+		if t.literal != 0 {
+			if strings.IndexByte(constPatternMetaChars, t.literal) >= 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(t.literal)
+			continue
+		}
+		sb.WriteByte('[')
+		if t.digit {
+			sb.WriteString("0-9")
+		}
+		switch {
+		case t.upper && t.hex:
+			sb.WriteString("A-F")
+		case t.upper:
+			sb.WriteString("A-Z")
+		}
+		switch {
+		case t.lower && t.hex:
+			sb.WriteString("a-f")
+		case t.lower:
+			sb.WriteString("a-z")
+		}
+		sb.WriteByte(']')
+		sb.WriteByte('{')
+		sb.WriteString(strconv.Itoa(t.min))
+		if t.max != t.min {
+			sb.WriteByte(',')
+			sb.WriteString(strconv.Itoa(t.max))
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte('$')
+	return sb.String()
+}
+
+// parsePattern read back the pattern written by renderPattern, other regex is not supported.
+func parsePattern(s string) ([]patternToken, bool) {
+	if len(s) < 2 || s[0] != '^' || s[len(s)-1] != '$' {
+		return nil, false
+	}
+	s = s[1 : len(s)-1]
+	var tokens []patternToken
+	for len(s) > 0 {
+		switch s[0] {
+		case '\\':
+			if len(s) < 2 {
+				return nil, false
+			}
+			tokens = append(tokens, patternToken{literal: s[1]})
+			s = s[2:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, false
+			}
+			t := patternToken{hex: true}
+			for class := s[1:end]; len(class) > 0; class = class[3:] {
+				if len(class) < 3 {
+					return nil, false
+				}
+				switch class[:3] {
+				case "0-9":
+					t.digit = true
+				case "A-Z", "A-F":
+					t.upper, t.hex = true, t.hex && class[:3] == "A-F"
+				case "a-z", "a-f":
+					t.lower, t.hex = true, t.hex && class[:3] == "a-f"
+				default:
+					return nil, false
+				}
+			}
+			t.hex = t.hex && t.letters()
+			s = s[end+1:]
+			end = strings.IndexByte(s, '}')
+			if len(s) == 0 || s[0] != '{' || end < 0 {
+				return nil, false
+			}
+			bounds := strings.SplitN(s[1:end], ",", 2)
+			var err error
+			if t.min, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, false
+			}
+			t.max = t.min
+			if len(bounds) == 2 {
+				if t.max, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, false
+				}
+			}
+			tokens = append(tokens, t)
+			s = s[end+1:]
+		default:
+			if strings.IndexByte(constPatternMetaChars, s[0]) >= 0 {
+				return nil, false
+			}
+			tokens = append(tokens, patternToken{literal: s[0]})
+			s = s[1:]
+		}
+	}
+	return tokens, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,78 @@
+package jsonschema
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_InferPattern(t *testing.T) {
+	cases := map[string]string{
+		"CN12345678":                       `^[A-Z]{2}[0-9]{8}$`,
+		"ORD-2023-0001":                    `^[A-Z]{3}-[0-9]{4}-[0-9]{4}$`,
+		"+86 138.0000":                     `^\+[0-9]{2} [0-9]{3}\.[0-9]{4}$`,
+		"USD":                              `^[A-Z]{3}$`,
+		"9f86d081884c7d659a2feaa0c55ad015": `^[0-9a-f]{32}$`,
+		"":                                 "",
+		"---":                              "",
+	}
+	for value, expected := range cases {
+		pattern := inferPattern(value)
+		if pattern != expected {
+			t.Errorf("%q: expected %s, got %s", value, expected, pattern)
+			continue
+		}
+		if pattern != "" && !regexp.MustCompile(pattern).MatchString(value) {
+			t.Errorf("%q does not match %s", value, pattern)
+		}
+	}
+}
+
+func Test_MergePattern(t *testing.T) {
+	samples := []string{"ORD-23-0001", "ORD-2023-01", "ord-2023-0001"}
+	pattern := inferPattern(samples[0])
+	for _, s := range samples[1:] {
+		pattern = mergePattern(pattern, inferPattern(s))
+	}
+	if pattern != `^[A-Za-z]{3}-[0-9]{2,4}-[0-9]{2,4}$` {
+		t.Errorf("unexpected pattern %s", pattern)
+	}
+	for _, s := range samples {
+		if !regexp.MustCompile(pattern).MatchString(s) {
+			t.Errorf("%q does not match %s", s, pattern)
+		}
+	}
+
+	hex := mergePattern(inferPattern("9f86d081884c7d659a2feaa0c55ad015"), inferPattern("12345678901234567890123456789012"))
+	if hex != `^[0-9a-f]{32}$` {
+		t.Errorf("unexpected hex pattern %s", hex)
+	}
+	if mergePattern(inferPattern("ORD-1"), inferPattern("hello world")) != "" {
+		t.Error("different shapes should not have pattern")
+	}
+}
+
+func Test_ParseStringFormat(t *testing.T) {
+	cases := map[string]string{
+		"0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0": "uuid",
+		"P3DT4H":                               "duration",
+		"10:20:30Z":                            "time",
+		"api.example.com":                      "hostname",
+		"CN12345678":                           "",
+	}
+	m := NewSchemaDataModel(nil, "")
+	p := &property{}
+	if m.parseString("new york", "", p); p.Pattern != "" {
+		t.Errorf("pattern %q is inferred by default", p.Pattern)
+	}
+	m.Pattern = true
+	for value, expected := range cases {
+		p := &property{}
+		m.parseString(value, "", p)
+		if p.Format != expected {
+			t.Errorf("%q: expected format %q, got %q", value, expected, p.Format)
+		}
+		if (p.Pattern == "") == (expected == "") {
+			t.Errorf("%q: pattern %q is unexpected for format %q", value, p.Pattern, p.Format)
+		}
+	}
+}
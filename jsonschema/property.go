@@ -4,7 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-cmp/cmp"
@@ -20,107 +21,129 @@ type SchemaDocument struct {
 	property
 }
 
-// Reads the variable structure into the JSON-Schema Document
-func (d *SchemaDocument) Read(variable interface{}) {
+// Reads the variable structure into the JSON-Schema Document, returns the error of the jsonschema tags
+func (d *SchemaDocument) Read(variable interface{}) error {
 	d.setDefaultSchema()
 
-	value := reflect.ValueOf(variable)
-	d.read(value.Type(), tagOptions(""))
+	return d.reflect(reflect.TypeOf(variable))
 }
 
 // MergeSchemaDocument merget y to current Schema Document
+// the occurrence of a shared def in $defs is un-hoisted before the sample is merged into it,
+// so that the sample widens only its own occurrence. the defs are extracted again after the merge
 func (d *SchemaDocument) MergeSchemaDocument(y *SchemaDocument) error {
 	if cmp.Equal(d.property, y.property) {
 		return nil
 	}
-	return mergeProperty(&d.property, &y.property)
-
+	sample := &y.property
+	if len(y.Defs) > 0 {
+		// the $ref of y are to the defs of y, they are not merged into d as references
+		var err error
+		if sample, err = y.property.unhoisted(nil, make(map[*property]bool)); err != nil {
+			return err
+		}
+		sample.Defs = nil
+	}
+	hoisted := len(d.Defs) > 0
+	err := mergeProperty(&d.property, sample)
+	if hoisted {
+		if rehoistErr := d.rehoistDefs(); rehoistErr != nil {
+			return rehoistErr
+		}
+	}
+	return err
 }
 
 // mergeProperty : merge Y to X
 func mergeProperty(x *property, y *property) error {
 	mergeStringProperty := func(a *property, b *property) {
+		// the format is kept only when all the samples satisfy it
 		if b.Format != a.Format {
-			if a.Format == "" {
-				a.Format = b.Format
-			} else {
-				fmt.Printf("error format not equal %s %s", a.Format, b.Format)
-			}
+			a.Format = ""
 		}
 
 		if len(b.Examples) > 0 {
 			a.Examples = append(a.Examples, b.Examples...)
 		}
 
-		if b.MaxLength > a.MaxLength {
-			a.MaxLength = b.MaxLength
-		}
-		if b.MinLength < a.MinLength {
-			a.MinLength = b.MinLength
-		}
+		a.MaxLength = maxCount(a.MaxLength, b.MaxLength)
+		a.MinLength = minCount(a.MinLength, b.MinLength)
+		a.Pattern = mergePattern(a.Pattern, b.Pattern)
 	}
 	mergeNullProperty := func(a *property, b *property) {
 		if a.Type == "null" {
 			a.Type = b.Type
 		}
 	}
-	mergeObjectProperty := func(a *property, b *property) {
-		if b.Items == nil {
-			return
-		}
-		if a.Items == nil {
-			a.Items = b.Items
-			return
-		}
-
-		err := mergeProperty(a.Items, b.Items)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		if b.Required != nil && a.Required == nil {
-			a.Required = b.Required
-			return
-		}
+	mergeObjectProperty := func(a *property, b *property) error {
+		// a property is required only when it is present in all the samples
 		a.Required = intersect(a.Required, b.Required)
-	}
-	mergeArrayProperty := func(a *property, b *property) {
 		if b.Properties == nil {
-			return
+			return nil
 		}
 		if a.Properties == nil {
 			a.Properties = b.Properties
-			return
-		}
-
-		if b.MaxItems > a.MaxItems {
-			a.MaxItems = b.MaxItems
-		}
-		if b.MinItems < a.MinItems {
-			a.MinItems = b.MinItems
+			return nil
 		}
 
+		var conflicts []string
 		for key, value := range b.Properties {
 			if _, ok := a.Properties[key]; ok {
-				err := mergeProperty(a.Properties[key], value)
-				if err != nil {
-					return
+				if err := mergeProperty(a.Properties[key], value); err != nil {
+					conflicts = append(conflicts, fieldConflicts("/"+key, err)...)
 				}
 			} else {
 				a.Properties[key] = value
 			}
 		}
+		return newMergeConflictError(conflicts)
 	}
-	mergeNumberProperty := func(a *property, b *property) {
-		if b.Minimum < a.Minimum {
-			a.Minimum = b.Minimum
-		}
-		if b.Maximum > a.Maximum {
-			a.Maximum = b.Maximum
+	mergeArrayProperty := func(a *property, b *property) error {
+		a.MaxItems = maxCount(a.MaxItems, b.MaxItems)
+		a.MinItems = minCount(a.MinItems, b.MinItems)
+		// unique only when the items of all the samples are unique
+		a.UniqueItems = a.UniqueItems && b.UniqueItems
+
+		var err error
+		switch {
+		case len(a.PrefixItems) > 0 && len(a.PrefixItems) == len(b.PrefixItems):
+			var conflicts []string
+			for i := range a.PrefixItems {
+				err := mergeProperty(a.PrefixItems[i], b.PrefixItems[i])
+				if _, nested := err.(*MergeConflictError); err != nil && !nested {
+					// the positions of different types are not a tuple
+					a.Items, err = mergeItems(append(a.tupleItems(), b.tupleItems()...))
+					a.PrefixItems = nil
+					return err
+				}
+				if err != nil {
+					conflicts = append(conflicts, fieldConflicts("/"+strconv.Itoa(i), err)...)
+				}
+			}
+			return newMergeConflictError(conflicts)
+		case len(a.PrefixItems) > 0 || len(b.PrefixItems) > 0:
+			// tuples of different length are not tuples
+			a.Items, err = mergeItems(append(a.tupleItems(), b.tupleItems()...))
+			a.PrefixItems = nil
+		case b.Items == nil:
+		case a.Items == nil:
+			a.Items = b.Items
+		default:
+			a.Items, err = mergeItems([]*property{a.Items, b.Items})
 		}
+		return err
+	}
+	mergeNumberProperty := func(a *property, b *property) {
+		a.Minimum = minNumber(a.Minimum, b.Minimum)
+		a.Maximum = maxNumber(a.Maximum, b.Maximum)
 		// TODO exclusiveMinimum exclusiveMaximum
+		// the smaller decimal step is a multiple of both, such as 0.01 of 0.1 and 0.01
+		a.MultipleOf = minNumber(a.MultipleOf, b.MultipleOf)
 		a.Examples = append(a.Examples, b.Examples...)
+		a.limitNumberExamples()
+		if a.Keywords[PercentilesKeyword] != nil || b.Keywords[PercentilesKeyword] != nil {
+			a.setPercentiles()
+		}
 	}
 	mergeIntegerProperty := func(a *property, b *property) {
 		mergeNumberProperty(a, b)
@@ -130,11 +153,31 @@ func mergeProperty(x *property, y *property) error {
 	mergeEnumProperty := func(a *property, b *property) {
 	}
 
+	if y.Ref != nil {
+		y = y.Ref
+	}
+	if x.Ref != nil {
+		if cmp.Equal(*x.Ref, *y) {
+			return nil
+		}
+		// the def is shared by the other occurrences, the sample is merged into the copy of this occurrence
+		c, err := x.Ref.copyProperty()
+		if err != nil {
+			return err
+		}
+		*x = *c
+	}
+
 	if cmp.Equal(*x, *y) {
 		return nil
 	}
 
-	if y.Type != x.Type && x.Type != "null" && y.Type != "null" {
+	// integer is widened to number when a fractional value is seen
+	if x.Type == "integer" && y.Type == "number" {
+		x.Type = "number"
+	}
+
+	if y.Type != x.Type && x.Type != "null" && y.Type != "null" && !(x.Type == "number" && y.Type == "integer") {
 		return fmt.Errorf("Type difference %s vs %s", x.Type, y.Type)
 	}
 
@@ -144,16 +187,16 @@ func mergeProperty(x *property, y *property) error {
 	case "null":
 		mergeNullProperty(x, y)
 	case "object":
-		mergeObjectProperty(x, y)
+		return mergeObjectProperty(x, y)
 	case "array":
-		mergeArrayProperty(x, y)
+		return mergeArrayProperty(x, y)
 	case "number":
 		mergeNumberProperty(x, y)
 	case "integer":
 		mergeIntegerProperty(x, y)
 	case "enum":
 		mergeEnumProperty(x, y)
-	case "bool":
+	case "boolean":
 		mergeBoolProperty(x, y)
 	default:
 		return fmt.Errorf("Type difference %s vs %s", x.Type, y.Type)
@@ -162,12 +205,11 @@ func mergeProperty(x *property, y *property) error {
 	return nil
 }
 
-// Compile the json data to json-schema
-func (d *SchemaDocument) Compile(variable interface{}) {
+// Compile the json data to json-schema, returns the error of the jsonschema tags
+func (d *SchemaDocument) Compile(variable interface{}) error {
 	d.setDefaultSchema()
 
-	value := reflect.ValueOf(variable)
-	d.read(value.Type(), tagOptions(""))
+	return d.reflect(reflect.TypeOf(variable))
 }
 
 func (d *SchemaDocument) setDefaultSchema() {
@@ -176,7 +218,7 @@ func (d *SchemaDocument) setDefaultSchema() {
 	}
 }
 
-// Marshal returns the JSON encoding of the Document
+// Marshal returns the JSON encoding of the Document, keywords are spelled by the draft of $schema
 func (d *SchemaDocument) Marshal() ([]byte, error) {
 	return json.MarshalIndent(d, "", "    ")
 }
@@ -200,248 +242,190 @@ func (d *SchemaDocument) String() (string, error) {
 // Draft2019-09的新内容 deprecated关键字是一个布尔值
 // Items有单例和多例[]*property,暂时只实现了单例
 type property struct {
-	Location string `json:"-"` // absolute location
+	Location string // absolute location. location of $defs when it is referenced by $ref.
 
 	// dynamicAnchors []*property
 
 	// annotations. captured only when Compiler.ExtractAnnotations is true.
-	Title       string        `json:"title,omitempty"`
-	Description string        `json:"description,omitempty"`
-	Default     interface{}   `json:"default,omitempty"`
-	Comment     string        `json:"comment,omitempty"`
-	Examples    []interface{} `json:"examples,omitempty"`
-	Deprecated  bool          `json:"deprecated,omitempty"`
-	ReadOnly    bool          `json:"readOnly,omitempty"`
-	WriteOnly   bool          `json:"writeOnly,omitempty"`
+	Title       string
+	Description string
+	Default     interface{}
+	Comment     string
+	Examples    []interface{}
+	Deprecated  bool
+	ReadOnly    bool
+	WriteOnly   bool
 
 	// type agnostic validations
-	Format          string        `json:"format,omitempty"`
-	Always          *bool         `json:"-"` // always pass/fail. used when booleans are used as schemas in draft-07.
-	Ref             *property     `json:"-"`
-	RecursiveAnchor bool          `json:"-"`
-	RecursiveRef    *property     `json:"-"`
-	DynamicAnchor   string        `json:"-"`
-	DynamicRef      *property     `json:"-"`
-	Type            string        `json:"type,omitempty"`
-	Types           []string      `json:"-"`              // allowed types.
-	Constant        []interface{} `json:"-"`              // first element in slice is constant value. note: slice is used to capture nil constant.
-	Enum            []interface{} `json:"enum,omitempty"` // allowed values.
+	Format          string
+	Always          *bool // always pass/fail. used when booleans are used as schemas in draft-07.
+	Ref             *property
+	RecursiveAnchor bool
+	RecursiveRef    *property
+	DynamicAnchor   string
+	DynamicRef      *property
+	Type            string
+	Types           []string      // allowed types.
+	Constant        []interface{} // first element in slice is constant value. note: slice is used to capture nil constant.
+	Enum            []interface{} // allowed values.
 	// enumError       string        // error message for enum fail. captured here to avoid constructing error message every time.
-	Not   *property   `json:"-"`
-	AllOf []*property `json:"-"`
-	AnyOf []*property `json:"-"`
-	OneOf []*property `json:"-"`
-	If    *property   `json:"-"`
-	Then  *property   `json:"-"` // nil, when If is nil.
-	Else  *property   `json:"-"` // nil, when If is nil.
+	Not   *property
+	AllOf []*property
+	AnyOf []*property
+	OneOf []*property
+	If    *property
+	Then  *property // nil, when If is nil.
+	Else  *property // nil, when If is nil.
 
 	// object validations
-	MinProperties         int                          `json:"-"`                  // -1 if not specified.
-	MaxProperties         int                          `json:"-"`                  // -1 if not specified.
-	Required              []string                     `json:"required,omitempty"` // list of required properties.
-	Properties            map[string]*property         `json:"properties,omitempty"`
-	PropertyNames         *property                    `json:"-"`
-	RegexProperties       bool                         `json:"-"` // property names must be valid regex. used only in draft4 as workaround in metaschema.
-	PatternProperties     map[*regexp.Regexp]*property `json:"-"`
-	AdditionalProperties  interface{}                  `json:"additionalProperties,omitempty"` // nil or bool or *property.
-	Dependencies          map[string]interface{}       `json:"-"`                              // map value is *property or []string.
-	DependentRequired     map[string][]string          `json:"-"`
-	DependentSchemas      map[string]*property         `json:"-"`
-	UnevaluatedProperties *property                    `json:"-"`
+	MinProperties         *int     // nil if not specified.
+	MaxProperties         *int     // nil if not specified.
+	Required              []string // list of required properties.
+	Properties            map[string]*property
+	PropertyNames         *property
+	RegexProperties       bool                   // property names must be valid regex. used only in draft4 as workaround in metaschema.
+	PatternProperties     map[string]*property   // key is the regex pattern.
+	AdditionalProperties  interface{}            // nil or bool or *property.
+	Dependencies          map[string]interface{} // map value is *property or []string.
+	DependentRequired     map[string][]string
+	DependentSchemas      map[string]*property
+	UnevaluatedProperties *property
 
 	// array validations
-	MinItems         int         `json:"minItems,omitempty"` // -1 if not specified.
-	MaxItems         int         `json:"maxItems,omitempty"` // -1 if not specified.
-	UniqueItems      bool        `json:"-"`
-	Items            *property   `json:"items,omitempty"`
-	AdditionalItems  interface{} `json:"-"` // nil or bool or *property.
-	PrefixItems      []*property `json:"-"`
-	Items2020        *property   `json:"-"` // items keyword reintroduced in draft 2020-12
-	Contains         *property   `json:"-"`
-	ContainsEval     bool        `json:"-"` // whether any item in an array that passes validation of the contains schema is considered "evaluated"
-	MinContains      int         `json:"-"` // 1 if not specified
-	MaxContains      int         `json:"-"` // -1 if not specified
-	UnevaluatedItems *property   `json:"-"`
+	MinItems         *int // nil if not specified.
+	MaxItems         *int // nil if not specified.
+	UniqueItems      bool
+	Items            *property   // additionalItems before draft 2020-12 when PrefixItems is set.
+	AdditionalItems  interface{} // nil or bool or *property. kept only when Items is not an array before draft 2020-12.
+	PrefixItems      []*property // items array before draft 2020-12.
+	Items2020        *property   // items keyword reintroduced in draft 2020-12
+	Contains         *property
+	ContainsEval     bool // whether any item in an array that passes validation of the contains schema is considered "evaluated"
+	MinContains      *int // nil if not specified
+	MaxContains      *int // nil if not specified
+	UnevaluatedItems *property
 
 	// string validations
-	MinLength        int            `json:"minLength,omitempty"` // -1 if not specified.
-	MaxLength        int            `json:"maxLength,omitempty"` // -1 if not specified.
-	Pattern          *regexp.Regexp `json:"-"`
-	ContentEncoding  string         `json:"-"`
-	ContentMediaType string         `json:"-"`
-	// mediaType        func([]byte) error `json:"-"`
-
-	// number validators
-	// Minimum          *big.Rat `json:"minimum,omitempty"`
-	// ExclusiveMinimum *big.Rat `json:"-"`
-	// Maximum          *big.Rat `json:"maximum,omitempty"`
-	// ExclusiveMaximum *big.Rat `json:"-"`
-	// MultipleOf       *big.Rat `json:"-"`
-	Minimum          float64 `json:"minimum,omitempty"`
-	ExclusiveMinimum float64 `json:"-"`
-	Maximum          float64 `json:"maximum,omitempty"`
-	ExclusiveMaximum float64 `json:"-"`
-	MultipleOf       float64 `json:"-"`
+	MinLength        *int   // nil if not specified.
+	MaxLength        *int   // nil if not specified.
+	Pattern          string // ECMA 262 regex, it is not compiled in document.
+	ContentEncoding  string
+	ContentMediaType string
+	// mediaType        func([]byte) error
+
+	// number validators. empty if not specified, json.Number keeps the precision of big number.
+	Minimum          json.Number
+	ExclusiveMinimum json.Number
+	Maximum          json.Number
+	ExclusiveMaximum json.Number
+	MultipleOf       json.Number
+
+	// reusable sub schemas. spelled definitions before draft 2019-09.
+	Defs map[string]*property
+
+	// keywords that are not modeled, such as $id, $anchor, $vocabulary. kept as they are for round trip.
+	Keywords map[string]interface{}
+
 	// user defined extensions
-	Extensions map[string]ExtSchema `json:"-"`
+	Extensions map[string]ExtSchema
 }
 
-func (p *property) read(t reflect.Type, opts tagOptions) {
-	jsType, format, kind := getTypeFromMapping(t)
-	if jsType != "" {
-		p.Type = jsType
-	}
-	if format != "" {
-		p.Format = format
-	}
-
-	switch kind {
-	case reflect.Slice:
-		p.readFromSlice(t)
-	case reflect.Map:
-		p.readFromMap(t)
-	case reflect.Struct:
-		p.readFromStruct(t)
-	case reflect.Ptr:
-		p.read(t.Elem(), opts)
+func union(slice1, slice2 []string) []string {
+	m := make(map[string]int)
+	for _, v := range slice1 {
+		m[v]++
 	}
-}
 
-func (p *property) readFromSlice(t reflect.Type) {
-	jsType, _, kind := getTypeFromMapping(t.Elem())
-	if kind == reflect.Uint8 {
-		p.Type = "string"
-	} else if jsType != "" {
-		oneItem := &property{}
-		oneItem.read(t.Elem(), tagOptions(""))
-		p.Items = oneItem
+	for _, v := range slice2 {
+		times, _ := m[v]
+		if times == 0 {
+			slice1 = append(slice1, v)
+		}
 	}
+	return slice1
 }
 
-func (p *property) readFromMap(t reflect.Type) {
-	jsType, format, _ := getTypeFromMapping(t.Elem())
-
-	if jsType != "" {
-		p.Properties = make(map[string]*property, 0)
-		p.Properties[".*"] = &property{Type: jsType, Format: format}
-	} else {
-		p.AdditionalProperties = true
+// mergeItems merge the schemas of array items into one, the items of different types are merged to their types.
+// the conflicts of the fields of items are returned as MergeConflictError under /*
+func mergeItems(items []*property) (*property, error) {
+	if len(items) == 0 {
+		return nil, nil
 	}
-}
-
-func (p *property) readFromStruct(t reflect.Type) {
-	p.Type = "object"
-	p.Properties = make(map[string]*property, 0)
-	p.AdditionalProperties = false
-
-	count := t.NumField()
-	for i := 0; i < count; i++ {
-		field := t.Field(i)
-
-		tag := field.Tag.Get("json")
-		name, opts := parseTag(tag)
-		if name == "" {
-			name = field.Name
-		}
-		if name == "-" {
-			continue
+	merged := items[0]
+	var conflicts []string
+	for _, item := range items[1:] {
+		err := mergeProperty(merged, item)
+		if _, nested := err.(*MergeConflictError); err != nil && !nested {
+			return &property{Types: itemTypes(items)}, nil
 		}
-
-		if field.Anonymous {
-			embeddedProperty := &property{}
-			embeddedProperty.read(field.Type, opts)
-
-			for name, property := range embeddedProperty.Properties {
-				p.Properties[name] = property
-			}
-			p.Required = append(p.Required, embeddedProperty.Required...)
-
-			continue
-		}
-
-		p.Properties[name] = &property{}
-		p.Properties[name].read(field.Type, opts)
-
-		if !opts.Contains("omitempty") {
-			p.Required = append(p.Required, name)
+		if err != nil {
+			conflicts = append(conflicts, fieldConflicts("/*", err)...)
 		}
 	}
+	return merged, newMergeConflictError(conflicts)
 }
 
-var kindMapping = map[reflect.Kind]string{
-	reflect.Bool:    "boolean",
-	reflect.Int:     "integer",
-	reflect.Int8:    "integer",
-	reflect.Int16:   "integer",
-	reflect.Int32:   "integer",
-	reflect.Int64:   "integer",
-	reflect.Uint:    "integer",
-	reflect.Uint8:   "integer",
-	reflect.Uint16:  "integer",
-	reflect.Uint32:  "integer",
-	reflect.Uint64:  "integer",
-	reflect.Float32: "number",
-	reflect.Float64: "number",
-	reflect.String:  "string",
-	reflect.Slice:   "array",
-	reflect.Struct:  "object",
-	reflect.Map:     "object",
+// MergeConflictError the fields of the sample whose types can not be merged into the schema.
+// the other fields are still merged, and the conflicting fields keep the schema before the merge
+type MergeConflictError struct {
+	Conflicts []string // json pointer of the field and the type difference, * stands for the items of array
 }
 
-func getTypeFromMapping(t reflect.Type) (string, string, reflect.Kind) {
-	if v, ok := formatMapping[t.String()]; ok {
-		return v[0], v[1], reflect.String
-	}
-
-	if v, ok := kindMapping[t.Kind()]; ok {
-		return v, "", t.Kind()
-	}
-
-	return "", "", t.Kind()
+func (e *MergeConflictError) Error() string {
+	return "merge conflicts: " + strings.Join(e.Conflicts, "; ")
 }
 
-type tagOptions string
-
-func parseTag(tag string) (string, tagOptions) {
-	if idx := strings.Index(tag, ","); idx != -1 {
-		return tag[:idx], tagOptions(tag[idx+1:])
+// newMergeConflictError the MergeConflictError of conflicts, nil when there is no conflict
+func newMergeConflictError(conflicts []string) error {
+	if len(conflicts) == 0 {
+		return nil
 	}
-	return tag, tagOptions("")
+	sort.Strings(conflicts)
+	return &MergeConflictError{Conflicts: conflicts}
 }
 
-func (o tagOptions) Contains(optionName string) bool {
-	if len(o) == 0 {
-		return false
+// fieldConflicts the conflicts of merging the field at path
+func fieldConflicts(path string, err error) []string {
+	nested, ok := err.(*MergeConflictError)
+	if !ok {
+		return []string{path + ": " + err.Error()}
 	}
+	conflicts := make([]string, 0, len(nested.Conflicts))
+	for _, conflict := range nested.Conflicts {
+		conflicts = append(conflicts, path+conflict)
+	}
+	return conflicts
+}
 
-	s := string(o)
-	for s != "" {
-		var next string
-		i := strings.Index(s, ",")
-		if i >= 0 {
-			s, next = s[:i], s[i+1:]
+// itemTypes the sorted distinct types of items, integer is widened to number when both are present.
+func itemTypes(items []*property) []string {
+	set := make(map[string]bool)
+	for _, item := range items {
+		if item.Type != "" {
+			set[item.Type] = true
 		}
-		if s == optionName {
-			return true
+		for _, t := range item.Types {
+			set[t] = true
 		}
-		s = next
 	}
-	return false
-}
-
-func union(slice1, slice2 []string) []string {
-	m := make(map[string]int)
-	for _, v := range slice1 {
-		m[v]++
+	if set["number"] {
+		delete(set, "integer")
 	}
+	types := make([]string, 0, len(set))
+	for t := range set {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
 
-	for _, v := range slice2 {
-		times, _ := m[v]
-		if times == 0 {
-			slice1 = append(slice1, v)
-		}
+// tupleItems the item schemas of array, the closing false of tuple is excluded.
+func (p *property) tupleItems() []*property {
+	items := append([]*property{}, p.PrefixItems...)
+	if p.Items != nil && p.Items.Always == nil {
+		items = append(items, p.Items)
 	}
-	return slice1
+	return items
 }
 
 //求交集
@@ -57,7 +57,7 @@ func (p *propertySuite) TestLoad(c *C) {
 				"UInteger32": {Type: "integer"},
 				"UInteger64": {Type: "integer"},
 				"String":     {Type: "string"},
-				"Bytes":      {Type: "string"},
+				"Bytes":      {Type: "string", ContentEncoding: "base64"},
 				"Float32":    {Type: "number"},
 				"Float64":    {Type: "number"},
 				"Interface":  {},
@@ -199,11 +199,8 @@ func (self *propertySuite) TestLoadMap(c *C) {
 			Type: "object",
 			Properties: map[string]*property{
 				"Maps": {
-					Type: "object",
-					Properties: map[string]*property{
-						".*": {Type: "string"},
-					},
-					AdditionalProperties: false,
+					Type:                 "object",
+					AdditionalProperties: &property{Type: "string"},
 				},
 				"MapOfInterface": {
 					Type:                 "object",
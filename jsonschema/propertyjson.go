@@ -0,0 +1,853 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// orderedMap keeps the keywords in insertion order when marshal to json
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]interface{})}
+}
+
+func (o *orderedMap) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON marshal keywords in insertion order
+func (o *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON returns the JSON encoding of the Document, keywords are spelled by the draft of $schema
+func (d SchemaDocument) MarshalJSON() ([]byte, error) {
+	draft := documentDraft(d.Schema)
+	if d.Always != nil {
+		return json.Marshal(*d.Always)
+	}
+	om := d.property.toOrderedMap(draft)
+	if d.Schema != "" {
+		om.keys = append([]string{"$schema"}, om.keys...)
+		om.values["$schema"] = d.Schema
+	}
+	return om.MarshalJSON()
+}
+
+// UnmarshalJSON parse the JSON encoding of the Document, keywords are read by the draft of $schema
+func (d *SchemaDocument) UnmarshalJSON(b []byte) error {
+	*d = SchemaDocument{}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keywords); err != nil {
+		var always bool
+		if json.Unmarshal(b, &always) != nil {
+			return err
+		}
+		d.Always = &always
+		return nil
+	}
+	if raw, ok := keywords["$schema"]; ok {
+		if err := json.Unmarshal(raw, &d.Schema); err != nil {
+			return fmt.Errorf("$schema: %v", err)
+		}
+		delete(keywords, "$schema")
+	}
+	if err := d.property.fromKeywords(keywords, documentDraft(d.Schema)); err != nil {
+		return err
+	}
+	d.resolveRefs()
+	return nil
+}
+
+func documentDraft(schema string) *Draft {
+	if draft := findDraft(schema); draft != nil {
+		return draft
+	}
+	return latest
+}
+
+// toValue convert property to json value of the draft, boolean schema is converted to bool
+func (p *property) toValue(draft *Draft) interface{} {
+	if p.Always != nil {
+		return *p.Always
+	}
+	return p.toOrderedMap(draft)
+}
+
+// toOrderedMap convert property to json keywords of the draft
+func (p *property) toOrderedMap(draft *Draft) *orderedMap {
+	om := newOrderedMap()
+	subValue := func(sub *property) interface{} {
+		return sub.toValue(draft)
+	}
+	subValues := func(subs []*property) []interface{} {
+		res := make([]interface{}, 0, len(subs))
+		for _, sub := range subs {
+			res = append(res, subValue(sub))
+		}
+		return res
+	}
+	subMaps := func(subs map[string]*property) map[string]interface{} {
+		res := make(map[string]interface{}, len(subs))
+		for name, sub := range subs {
+			res[name] = subValue(sub)
+		}
+		return res
+	}
+	schemaOrBool := func(v interface{}) interface{} {
+		if sub, ok := v.(*property); ok {
+			return subValue(sub)
+		}
+		return v
+	}
+
+	if p.Ref != nil {
+		om.set("$ref", refLocation(p.Ref.Location, draft))
+	}
+	if p.RecursiveRef != nil && draft.version == 2019 {
+		om.set("$recursiveRef", p.RecursiveRef.Location)
+	}
+	if p.RecursiveAnchor && draft.version == 2019 {
+		om.set("$recursiveAnchor", p.RecursiveAnchor)
+	}
+	if p.DynamicRef != nil && draft.version >= 2020 {
+		om.set("$dynamicRef", p.DynamicRef.Location)
+	}
+	if p.DynamicAnchor != "" && draft.version >= 2020 {
+		om.set("$dynamicAnchor", p.DynamicAnchor)
+	}
+
+	// annotations
+	if p.Title != "" {
+		om.set("title", p.Title)
+	}
+	if p.Description != "" {
+		om.set("description", p.Description)
+	}
+	if p.Default != nil {
+		om.set("default", p.Default)
+	}
+	if p.Comment != "" && draft.version >= 7 {
+		om.set("$comment", p.Comment)
+	}
+	if len(p.Examples) > 0 && draft.version >= 6 {
+		om.set("examples", p.Examples)
+	}
+	if p.Deprecated && draft.version >= 2019 {
+		om.set("deprecated", p.Deprecated)
+	}
+	if p.ReadOnly && draft.version >= 7 {
+		om.set("readOnly", p.ReadOnly)
+	}
+	if p.WriteOnly && draft.version >= 7 {
+		om.set("writeOnly", p.WriteOnly)
+	}
+
+	// type agnostic validations
+	if p.Format != "" {
+		om.set("format", p.Format)
+	}
+	if p.Type != "" {
+		om.set("type", p.Type)
+	} else if len(p.Types) > 0 {
+		om.set("type", p.Types)
+	}
+	if len(p.Constant) > 0 && draft.version >= 6 {
+		om.set("const", p.Constant[0])
+	}
+	if p.Enum != nil {
+		om.set("enum", p.Enum)
+	}
+	if p.Not != nil {
+		om.set("not", subValue(p.Not))
+	}
+	if p.AllOf != nil {
+		om.set("allOf", subValues(p.AllOf))
+	}
+	if p.AnyOf != nil {
+		om.set("anyOf", subValues(p.AnyOf))
+	}
+	if p.OneOf != nil {
+		om.set("oneOf", subValues(p.OneOf))
+	}
+	if p.If != nil && draft.version >= 7 {
+		om.set("if", subValue(p.If))
+		if p.Then != nil {
+			om.set("then", subValue(p.Then))
+		}
+		if p.Else != nil {
+			om.set("else", subValue(p.Else))
+		}
+	}
+
+	// object validations
+	if p.MinProperties != nil {
+		om.set("minProperties", *p.MinProperties)
+	}
+	if p.MaxProperties != nil {
+		om.set("maxProperties", *p.MaxProperties)
+	}
+	if len(p.Required) > 0 {
+		om.set("required", p.Required)
+	}
+	if p.Properties != nil {
+		om.set("properties", subMaps(p.Properties))
+	}
+	if p.PropertyNames != nil && draft.version >= 6 {
+		om.set("propertyNames", subValue(p.PropertyNames))
+	}
+	if p.PatternProperties != nil {
+		om.set("patternProperties", subMaps(p.PatternProperties))
+	}
+	if p.AdditionalProperties != nil {
+		om.set("additionalProperties", schemaOrBool(p.AdditionalProperties))
+	}
+	if p.Dependencies != nil && draft.version < 2019 {
+		deps := make(map[string]interface{}, len(p.Dependencies))
+		for name, dep := range p.Dependencies {
+			deps[name] = schemaOrBool(dep)
+		}
+		om.set("dependencies", deps)
+	}
+	if p.DependentRequired != nil && draft.version >= 2019 {
+		om.set("dependentRequired", p.DependentRequired)
+	}
+	if p.DependentSchemas != nil && draft.version >= 2019 {
+		om.set("dependentSchemas", subMaps(p.DependentSchemas))
+	}
+	if p.UnevaluatedProperties != nil && draft.version >= 2019 {
+		om.set("unevaluatedProperties", subValue(p.UnevaluatedProperties))
+	}
+
+	// array validations
+	if p.MinItems != nil {
+		om.set("minItems", *p.MinItems)
+	}
+	if p.MaxItems != nil {
+		om.set("maxItems", *p.MaxItems)
+	}
+	if p.UniqueItems {
+		om.set("uniqueItems", p.UniqueItems)
+	}
+	if p.PrefixItems != nil {
+		if draft.version >= 2020 {
+			om.set("prefixItems", subValues(p.PrefixItems))
+			if p.Items != nil {
+				om.set("items", subValue(p.Items))
+			}
+		} else {
+			om.set("items", subValues(p.PrefixItems))
+			if p.Items != nil {
+				om.set("additionalItems", subValue(p.Items))
+			}
+		}
+	} else if p.Items != nil {
+		om.set("items", subValue(p.Items))
+	}
+	if p.AdditionalItems != nil && draft.version < 2020 {
+		om.set("additionalItems", schemaOrBool(p.AdditionalItems))
+	}
+	if p.Contains != nil && draft.version >= 6 {
+		om.set("contains", subValue(p.Contains))
+	}
+	if p.MinContains != nil && draft.version >= 2019 {
+		om.set("minContains", *p.MinContains)
+	}
+	if p.MaxContains != nil && draft.version >= 2019 {
+		om.set("maxContains", *p.MaxContains)
+	}
+	if p.UnevaluatedItems != nil && draft.version >= 2019 {
+		om.set("unevaluatedItems", subValue(p.UnevaluatedItems))
+	}
+
+	// string validations
+	if p.MinLength != nil {
+		om.set("minLength", *p.MinLength)
+	}
+	if p.MaxLength != nil {
+		om.set("maxLength", *p.MaxLength)
+	}
+	if p.Pattern != "" {
+		om.set("pattern", p.Pattern)
+	}
+	if p.ContentEncoding != "" && draft.version >= 7 {
+		om.set("contentEncoding", p.ContentEncoding)
+	}
+	if p.ContentMediaType != "" && draft.version >= 7 {
+		om.set("contentMediaType", p.ContentMediaType)
+	}
+
+	// number validations
+	if p.Minimum != "" {
+		om.set("minimum", p.Minimum)
+	}
+	if p.ExclusiveMinimum != "" {
+		if draft.version == 4 {
+			om.set("minimum", p.ExclusiveMinimum)
+			om.set("exclusiveMinimum", true)
+		} else {
+			om.set("exclusiveMinimum", p.ExclusiveMinimum)
+		}
+	}
+	if p.Maximum != "" {
+		om.set("maximum", p.Maximum)
+	}
+	if p.ExclusiveMaximum != "" {
+		if draft.version == 4 {
+			om.set("maximum", p.ExclusiveMaximum)
+			om.set("exclusiveMaximum", true)
+		} else {
+			om.set("exclusiveMaximum", p.ExclusiveMaximum)
+		}
+	}
+	if p.MultipleOf != "" {
+		om.set("multipleOf", p.MultipleOf)
+	}
+
+	// reusable sub schemas
+	if p.Defs != nil {
+		if draft.version >= 2019 {
+			om.set("$defs", subMaps(p.Defs))
+		} else {
+			om.set("definitions", subMaps(p.Defs))
+		}
+	}
+
+	for _, key := range sortedKeywords(p.Keywords) {
+		if _, ok := om.values[key]; !ok {
+			om.set(key, p.Keywords[key])
+		}
+	}
+	return om
+}
+
+// keywordDrafts the draft range of keywords that are not available in every draft. [since, until]
+var keywordDrafts = map[string][2]int{
+	"$recursiveRef":         {2019, 2019},
+	"$recursiveAnchor":      {2019, 2019},
+	"$dynamicRef":           {2020, 2020},
+	"$dynamicAnchor":        {2020, 2020},
+	"$comment":              {7, 2020},
+	"examples":              {6, 2020},
+	"deprecated":            {2019, 2020},
+	"readOnly":              {7, 2020},
+	"writeOnly":             {7, 2020},
+	"const":                 {6, 2020},
+	"if":                    {7, 2020},
+	"then":                  {7, 2020},
+	"else":                  {7, 2020},
+	"propertyNames":         {6, 2020},
+	"dependencies":          {4, 7},
+	"dependentRequired":     {2019, 2020},
+	"dependentSchemas":      {2019, 2020},
+	"unevaluatedProperties": {2019, 2020},
+	"prefixItems":           {2020, 2020},
+	"additionalItems":       {4, 2019},
+	"contains":              {6, 2020},
+	"minContains":           {2019, 2020},
+	"maxContains":           {2019, 2020},
+	"unevaluatedItems":      {2019, 2020},
+	"contentEncoding":       {7, 2020},
+	"contentMediaType":      {7, 2020},
+	"$defs":                 {2019, 2020},
+	"definitions":           {4, 7},
+}
+
+// fromValue parse the json value of sub schema, boolean schema is parsed to Always
+func (p *property) fromValue(raw json.RawMessage, draft *Draft) error {
+	var always bool
+	if err := json.Unmarshal(raw, &always); err == nil {
+		p.Always = &always
+		return nil
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keywords); err != nil {
+		return err
+	}
+	return p.fromKeywords(keywords, draft)
+}
+
+// fromKeywords parse json keywords of the draft to property
+func (p *property) fromKeywords(keywords map[string]json.RawMessage, draft *Draft) error {
+	sub := func(raw json.RawMessage) (*property, error) {
+		s := &property{}
+		return s, s.fromValue(raw, draft)
+	}
+	subs := func(raw json.RawMessage) ([]*property, error) {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(raw, &raws); err != nil {
+			return nil, err
+		}
+		res := make([]*property, 0, len(raws))
+		for _, r := range raws {
+			s, err := sub(r)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, s)
+		}
+		return res, nil
+	}
+	subMaps := func(raw json.RawMessage) (map[string]*property, error) {
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &raws); err != nil {
+			return nil, err
+		}
+		res := make(map[string]*property, len(raws))
+		for name, r := range raws {
+			s, err := sub(r)
+			if err != nil {
+				return nil, err
+			}
+			res[name] = s
+		}
+		return res, nil
+	}
+	schemaOrBool := func(raw json.RawMessage) (interface{}, error) {
+		var b bool
+		if err := json.Unmarshal(raw, &b); err == nil {
+			return b, nil
+		}
+		return sub(raw)
+	}
+	ref := func(raw json.RawMessage) (*property, error) {
+		var location string
+		err := json.Unmarshal(raw, &location)
+		return &property{Location: location}, err
+	}
+
+	var additionalItems json.RawMessage
+	var itemsArray bool
+	for key, raw := range keywords {
+		if r, ok := keywordDrafts[key]; ok && (draft.version < r[0] || draft.version > r[1]) {
+			if err := p.setKeyword(key, raw); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var err error
+		switch key {
+		case "$ref":
+			p.Ref, err = ref(raw)
+		case "$recursiveRef":
+			p.RecursiveRef, err = ref(raw)
+		case "$recursiveAnchor":
+			err = json.Unmarshal(raw, &p.RecursiveAnchor)
+		case "$dynamicRef":
+			p.DynamicRef, err = ref(raw)
+		case "$dynamicAnchor":
+			err = json.Unmarshal(raw, &p.DynamicAnchor)
+
+		// annotations
+		case "title":
+			err = json.Unmarshal(raw, &p.Title)
+		case "description":
+			err = json.Unmarshal(raw, &p.Description)
+		case "default":
+			p.Default, err = decodeValue(raw)
+		case "$comment":
+			err = json.Unmarshal(raw, &p.Comment)
+		case "examples":
+			var v interface{}
+			if v, err = decodeValue(raw); err == nil {
+				examples, ok := v.([]interface{})
+				if !ok {
+					err = fmt.Errorf("expected array")
+				}
+				p.Examples = examples
+			}
+		case "deprecated":
+			err = json.Unmarshal(raw, &p.Deprecated)
+		case "readOnly":
+			err = json.Unmarshal(raw, &p.ReadOnly)
+		case "writeOnly":
+			err = json.Unmarshal(raw, &p.WriteOnly)
+
+		// type agnostic validations
+		case "format":
+			err = json.Unmarshal(raw, &p.Format)
+		case "type":
+			if json.Unmarshal(raw, &p.Type) != nil {
+				err = json.Unmarshal(raw, &p.Types)
+			}
+		case "const":
+			var v interface{}
+			v, err = decodeValue(raw)
+			p.Constant = []interface{}{v}
+		case "enum":
+			var v interface{}
+			if v, err = decodeValue(raw); err == nil {
+				enum, ok := v.([]interface{})
+				if !ok {
+					err = fmt.Errorf("expected array")
+				}
+				p.Enum = enum
+			}
+		case "not":
+			p.Not, err = sub(raw)
+		case "allOf":
+			p.AllOf, err = subs(raw)
+		case "anyOf":
+			p.AnyOf, err = subs(raw)
+		case "oneOf":
+			p.OneOf, err = subs(raw)
+		case "if":
+			p.If, err = sub(raw)
+		case "then":
+			p.Then, err = sub(raw)
+		case "else":
+			p.Else, err = sub(raw)
+
+		// object validations
+		case "minProperties":
+			p.MinProperties, err = decodeCount(raw)
+		case "maxProperties":
+			p.MaxProperties, err = decodeCount(raw)
+		case "required":
+			err = json.Unmarshal(raw, &p.Required)
+		case "properties":
+			p.Properties, err = subMaps(raw)
+		case "propertyNames":
+			p.PropertyNames, err = sub(raw)
+		case "patternProperties":
+			p.PatternProperties, err = subMaps(raw)
+		case "additionalProperties":
+			p.AdditionalProperties, err = schemaOrBool(raw)
+		case "dependencies":
+			var raws map[string]json.RawMessage
+			if err = json.Unmarshal(raw, &raws); err == nil {
+				p.Dependencies = make(map[string]interface{}, len(raws))
+				for name, r := range raws {
+					var required []string
+					if json.Unmarshal(r, &required) == nil {
+						p.Dependencies[name] = required
+					} else if p.Dependencies[name], err = schemaOrBool(r); err != nil {
+						break
+					}
+				}
+			}
+		case "dependentRequired":
+			err = json.Unmarshal(raw, &p.DependentRequired)
+		case "dependentSchemas":
+			p.DependentSchemas, err = subMaps(raw)
+		case "unevaluatedProperties":
+			p.UnevaluatedProperties, err = sub(raw)
+
+		// array validations
+		case "minItems":
+			p.MinItems, err = decodeCount(raw)
+		case "maxItems":
+			p.MaxItems, err = decodeCount(raw)
+		case "uniqueItems":
+			err = json.Unmarshal(raw, &p.UniqueItems)
+		case "prefixItems":
+			p.PrefixItems, err = subs(raw)
+		case "items":
+			if draft.version < 2020 && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+				itemsArray = true
+				p.PrefixItems, err = subs(raw)
+			} else {
+				p.Items, err = sub(raw)
+			}
+		case "additionalItems":
+			additionalItems = raw
+		case "contains":
+			p.Contains, err = sub(raw)
+		case "minContains":
+			p.MinContains, err = decodeCount(raw)
+		case "maxContains":
+			p.MaxContains, err = decodeCount(raw)
+		case "unevaluatedItems":
+			p.UnevaluatedItems, err = sub(raw)
+
+		// string validations
+		case "minLength":
+			p.MinLength, err = decodeCount(raw)
+		case "maxLength":
+			p.MaxLength, err = decodeCount(raw)
+		case "pattern":
+			err = json.Unmarshal(raw, &p.Pattern)
+		case "contentEncoding":
+			err = json.Unmarshal(raw, &p.ContentEncoding)
+		case "contentMediaType":
+			err = json.Unmarshal(raw, &p.ContentMediaType)
+
+		// number validations
+		case "minimum":
+			err = json.Unmarshal(raw, &p.Minimum)
+		case "maximum":
+			err = json.Unmarshal(raw, &p.Maximum)
+		case "multipleOf":
+			err = json.Unmarshal(raw, &p.MultipleOf)
+		case "exclusiveMinimum", "exclusiveMaximum":
+			if draft.version == 4 {
+				// exclusive flag of draft4 is read after minimum and maximum
+				continue
+			}
+			if key == "exclusiveMinimum" {
+				err = json.Unmarshal(raw, &p.ExclusiveMinimum)
+			} else {
+				err = json.Unmarshal(raw, &p.ExclusiveMaximum)
+			}
+
+		// reusable sub schemas
+		case "$defs", "definitions":
+			p.Defs, err = subMaps(raw)
+
+		default:
+			err = p.setKeyword(key, raw)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	if additionalItems != nil {
+		var err error
+		if itemsArray {
+			p.Items, err = sub(additionalItems)
+		} else {
+			p.AdditionalItems, err = schemaOrBool(additionalItems)
+		}
+		if err != nil {
+			return fmt.Errorf("additionalItems: %v", err)
+		}
+	}
+
+	if draft.version == 4 {
+		exclusive := func(key string, bound *json.Number, exclusiveBound *json.Number) error {
+			raw, ok := keywords[key]
+			if !ok {
+				return nil
+			}
+			var flag bool
+			if err := json.Unmarshal(raw, &flag); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			if flag && *bound != "" {
+				*exclusiveBound, *bound = *bound, ""
+			} else if !flag {
+				return p.setKeyword(key, raw)
+			}
+			return nil
+		}
+		if err := exclusive("exclusiveMinimum", &p.Minimum, &p.ExclusiveMinimum); err != nil {
+			return err
+		}
+		if err := exclusive("exclusiveMaximum", &p.Maximum, &p.ExclusiveMaximum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setKeyword keep the keyword that is not modeled
+func (p *property) setKeyword(key string, raw json.RawMessage) error {
+	v, err := decodeValue(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	if p.Keywords == nil {
+		p.Keywords = make(map[string]interface{})
+	}
+	p.Keywords[key] = v
+	return nil
+}
+
+// resolveRefs link the local $ref of $defs to the sub schema of Defs
+func (d *SchemaDocument) resolveRefs() {
+	if len(d.Defs) == 0 {
+		return
+	}
+	for name, def := range d.Defs {
+		def.Location = constDefsPrefix + escapePointer(name)
+	}
+	d.property.walk(func(p *property) {
+		if p.Ref == nil || p.Ref.Location == "" {
+			return
+		}
+		location := p.Ref.Location
+		for _, prefix := range []string{constDefsPrefix, "#/definitions/"} {
+			if !strings.HasPrefix(location, prefix) {
+				continue
+			}
+			if def, ok := d.Defs[unescapePointer(strings.TrimPrefix(location, prefix))]; ok {
+				p.Ref = def
+			}
+		}
+	})
+}
+
+// walk call fn with the property and all of its sub properties, $ref is not followed.
+func (p *property) walk(fn func(p *property)) {
+	fn(p)
+	walkOne := func(sub *property) {
+		if sub != nil {
+			sub.walk(fn)
+		}
+	}
+	walkMap := func(subs map[string]*property) {
+		for _, sub := range subs {
+			sub.walk(fn)
+		}
+	}
+	walkAny := func(v interface{}) {
+		if sub, ok := v.(*property); ok {
+			sub.walk(fn)
+		}
+	}
+
+	for _, v := range [...]*property{p.Not, p.If, p.Then, p.Else, p.PropertyNames, p.UnevaluatedProperties,
+		p.Items, p.Items2020, p.Contains, p.UnevaluatedItems} {
+		walkOne(v)
+	}
+	for _, list := range [...][]*property{p.AllOf, p.AnyOf, p.OneOf, p.PrefixItems} {
+		for _, sub := range list {
+			sub.walk(fn)
+		}
+	}
+	for _, m := range [...]map[string]*property{p.Properties, p.PatternProperties, p.DependentSchemas, p.Defs} {
+		walkMap(m)
+	}
+	walkAny(p.AdditionalProperties)
+	walkAny(p.AdditionalItems)
+	for _, dep := range p.Dependencies {
+		walkAny(dep)
+	}
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func unescapePointer(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
+
+// decodeValue decode json value, number is kept as json.Number
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// decodeCount decode the non-negative integer keyword, such as 2 or 2.0
+func decodeCount(raw json.RawMessage) (*int, error) {
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil, err
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	if f != float64(int(f)) {
+		return nil, fmt.Errorf("expected integer, but got %s", n)
+	}
+	return intPtr(int(f)), nil
+}
+
+func sortedKeywords(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatNumber(f float64) json.Number {
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// compareNumber returns -1, 0, +1 when a < b, a == b, a > b
+func compareNumber(a, b json.Number) int {
+	x, okx := new(big.Rat).SetString(string(a))
+	y, oky := new(big.Rat).SetString(string(b))
+	if !okx || !oky {
+		return strings.Compare(string(a), string(b))
+	}
+	return x.Cmp(y)
+}
+
+// minNumber the lower bound of a and b, empty is unbounded
+func minNumber(a, b json.Number) json.Number {
+	if a == "" || b == "" {
+		return ""
+	}
+	if compareNumber(b, a) < 0 {
+		return b
+	}
+	return a
+}
+
+// maxNumber the upper bound of a and b, empty is unbounded
+func maxNumber(a, b json.Number) json.Number {
+	if a == "" || b == "" {
+		return ""
+	}
+	if compareNumber(b, a) > 0 {
+		return b
+	}
+	return a
+}
+
+// minCount the lower bound of a and b, nil is unbounded
+func minCount(a, b *int) *int {
+	if a == nil || b == nil {
+		return nil
+	}
+	if *b < *a {
+		return b
+	}
+	return a
+}
+
+// maxCount the upper bound of a and b, nil is unbounded
+func maxCount(a, b *int) *int {
+	if a == nil || b == nil {
+		return nil
+	}
+	if *b > *a {
+		return b
+	}
+	return a
+}
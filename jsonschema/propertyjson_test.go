@@ -0,0 +1,158 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func Test_MarshalByDraft(t *testing.T) {
+	p := property{
+		Type: "array",
+		PrefixItems: []*property{
+			{Type: "number", ExclusiveMinimum: "1"},
+		},
+		Items: &property{Type: "string"},
+		Defs: map[string]*property{
+			"name": {Type: "string"},
+		},
+	}
+
+	cases := []struct {
+		version  int
+		expected map[string]bool
+	}{
+		{4, map[string]bool{"items": true, "additionalItems": true, "definitions": true}},
+		{7, map[string]bool{"items": true, "additionalItems": true, "definitions": true}},
+		{2019, map[string]bool{"items": true, "additionalItems": true, "$defs": true}},
+		{2020, map[string]bool{"prefixItems": true, "items": true, "$defs": true}},
+	}
+	for _, c := range cases {
+		_, text := readDraft(c.version)
+		doc := SchemaDocument{Schema: text, property: p}
+		data, err := doc.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keywords := make(map[string]interface{})
+		json.Unmarshal(data, &keywords)
+		for k := range c.expected {
+			if _, ok := keywords[k]; !ok {
+				t.Errorf("draft %d: keyword %s is missing in %s", c.version, k, data)
+			}
+		}
+		if _, err := CompileString("draft.json", string(data)); err != nil {
+			t.Errorf("draft %d: %v", c.version, err)
+		}
+	}
+}
+
+func Test_ExclusiveMinimumByDraft(t *testing.T) {
+	p := property{Type: "number", ExclusiveMinimum: "1"}
+	for version, expected := range map[int]interface{}{4: true, 6: 1.0, 2020: 1.0} {
+		_, text := readDraft(version)
+		data, _ := SchemaDocument{Schema: text, property: p}.MarshalJSON()
+		keywords := make(map[string]interface{})
+		json.Unmarshal(data, &keywords)
+		if keywords["exclusiveMinimum"] != expected {
+			t.Errorf("draft %d: unexpected exclusiveMinimum in %s", version, data)
+		}
+	}
+}
+
+func Test_DraftVersion(t *testing.T) {
+	for name, expected := range map[string]int{"4": 4, "draft-07": 7, "2019-09": 2019, "2020-12": 2020} {
+		version, err := DraftVersion(name)
+		if err != nil || version != expected {
+			t.Errorf("%s: expected %d, got %d %v", name, expected, version, err)
+		}
+	}
+	if _, err := DraftVersion("5"); err == nil {
+		t.Error("draft 5 should not be supported")
+	}
+}
+
+// Test_RoundTrip unmarshal every schema of the JSON-Schema-Test-Suite into SchemaDocument, marshal it back,
+// and checks the validation results are unchanged.
+func Test_RoundTrip(t *testing.T) {
+	const suite = "../testdata/JSON-Schema-Test-Suite@3fcee38/tests/"
+	const url = "http://localhost:1234/roundtrip.json"
+	for _, version := range []int{4, 6, 7, 2019, 2020} {
+		draft, text := readDraft(version)
+		folder := suite + "draft" + map[int]string{4: "4", 6: "6", 7: "7", 2019: "2019-09", 2020: "2020-12"}[version]
+		files, err := filepath.Glob(folder + "/*.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var groups []struct {
+				Description string
+				Schema      json.RawMessage
+				Tests       []struct {
+					Description string
+					Data        json.RawMessage
+				}
+			}
+			if err := json.Unmarshal(data, &groups); err != nil {
+				t.Fatal(err)
+			}
+			for _, group := range groups {
+				name := fmt.Sprintf("draft%d/%s/%s", version, filepath.Base(file), group.Description)
+				compile := func(schema []byte) (*Schema, error) {
+					c := NewCompiler()
+					c.Draft = draft
+					if err := c.AddResource(url, bytes.NewReader(schema)); err != nil {
+						return nil, err
+					}
+					return c.Compile(url)
+				}
+				expected, err := compile(group.Schema)
+				if err != nil {
+					// remote $ref is not served
+					continue
+				}
+
+				// the draft of the folder is read by $schema
+				schema := group.Schema
+				keywords := make(map[string]json.RawMessage)
+				if json.Unmarshal(schema, &keywords) == nil {
+					if _, ok := keywords["$schema"]; !ok {
+						keywords["$schema"], _ = json.Marshal(text)
+						schema, _ = json.Marshal(keywords)
+					}
+				}
+				var doc SchemaDocument
+				if err := json.Unmarshal(schema, &doc); err != nil {
+					t.Errorf("%s: %v", name, err)
+					continue
+				}
+				roundtrip, err := doc.Marshal()
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+					continue
+				}
+				actual, err := compile(roundtrip)
+				if err != nil {
+					t.Errorf("%s: %v\n%s", name, err, roundtrip)
+					continue
+				}
+				for _, test := range group.Tests {
+					v, err := decodeValue(test.Data)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if (expected.Validate(v) == nil) != (actual.Validate(v) == nil) {
+						t.Errorf("%s/%s: validation changed by\n%s", name, test.Description, roundtrip)
+					}
+				}
+			}
+		}
+	}
+}
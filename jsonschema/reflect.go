@@ -0,0 +1,453 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Reflect generate the json-schema document of the go value by reflection, spelled in the draft version.
+// json tags name the properties, fields without omitempty are required, pointers are nullable,
+// and jsonschema tags add validations, such as `jsonschema:"description=order id,minimum=1,enum=A|B"`.
+func Reflect(variable interface{}, version int) (*SchemaDocument, error) {
+	d := &SchemaDocument{}
+	_, d.Schema = readDraft(version)
+	err := d.reflect(reflect.TypeOf(variable))
+	return d, err
+}
+
+func (d *SchemaDocument) reflect(t reflect.Type) error {
+	if t == nil {
+		return nil
+	}
+	r := &reflector{
+		root:      &d.property,
+		visiting:  make(map[reflect.Type]*property),
+		embedding: make(map[reflect.Type]bool),
+	}
+	r.read(&d.property, t)
+	return r.err
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+// reflector read go types into the properties of root document
+type reflector struct {
+	root      *property
+	visiting  map[reflect.Type]*property // structs being read, the recursive type is referenced by $ref
+	embedding map[reflect.Type]bool      // embedded structs being read
+	err       error                      // the first error of jsonschema tags
+}
+
+func (r *reflector) read(p *property, t reflect.Type) {
+	if t.Kind() == reflect.Ptr {
+		r.read(p, t.Elem())
+		return
+	}
+
+	jsType, format, kind := getTypeFromMapping(t)
+	switch {
+	case jsType == "string" && format != "":
+		// the mapped type such as time.Time is a formatted string, though it is a json.Marshaler
+	case t == jsonNumberType:
+		p.Type = "number"
+		return
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// the json is customized, nothing is known
+		return
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		p.Type = "string"
+		return
+	}
+	if jsType != "" {
+		p.Type = jsType
+	}
+	if format != "" {
+		p.Format = format
+	}
+
+	switch kind {
+	case reflect.Slice, reflect.Array:
+		r.readFromSlice(p, t)
+	case reflect.Map:
+		r.readFromMap(p, t)
+	case reflect.Struct:
+		r.readFromStruct(p, t)
+	}
+}
+
+// typeProperty the property of type t, pointer is nullable
+func (r *reflector) typeProperty(t reflect.Type) *property {
+	p := &property{}
+	r.read(p, t)
+	if t.Kind() == reflect.Ptr {
+		p.setNullable()
+	}
+	return p
+}
+
+func (r *reflector) readFromSlice(p *property, t reflect.Type) {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		// []byte is encoded as base64 string
+		p.Type = "string"
+		p.ContentEncoding = "base64"
+		return
+	}
+	if t.Kind() == reflect.Array {
+		p.MinItems = intPtr(t.Len())
+		p.MaxItems = intPtr(t.Len())
+	}
+	if t.Elem().Kind() == reflect.Interface {
+		return
+	}
+	p.Items = r.typeProperty(t.Elem())
+}
+
+func (r *reflector) readFromMap(p *property, t reflect.Type) {
+	if t.Elem().Kind() == reflect.Interface {
+		p.AdditionalProperties = true
+		return
+	}
+	p.AdditionalProperties = r.typeProperty(t.Elem())
+}
+
+func (r *reflector) readFromStruct(p *property, t reflect.Type) {
+	if target, ok := r.visiting[t]; ok {
+		p.Type = ""
+		p.Ref = r.defOf(t, target)
+		return
+	}
+	r.visiting[t] = p
+	defer delete(r.visiting, t)
+
+	p.Type = "object"
+	p.Properties = make(map[string]*property, 0)
+	r.readFields(p, t, true)
+}
+
+// defOf reference the recursive struct, it is put into $defs unless it is the root
+func (r *reflector) defOf(t reflect.Type, target *property) *property {
+	if target == r.root {
+		target.Location = "#"
+		return target
+	}
+	if target.Location == "" {
+		if r.root.Defs == nil {
+			r.root.Defs = make(map[string]*property)
+		}
+		name := uniqueDefName(t.Name(), r.root.Defs)
+		target.Location = constDefsPrefix + name
+		r.root.Defs[name] = target
+	}
+	return target
+}
+
+// readFields read the exported fields of struct t into p, the fields of embedded structs are promoted.
+// required is false for the fields of nil-able embedded pointers.
+func (r *reflector) readFields(p *property, t reflect.Type, required bool) {
+	count := t.NumField()
+	for i := 0; i < count; i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		name, opts := parseTag(tag)
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !r.embedding[ft] {
+				r.embedding[ft] = true
+				embeddedProperty := &property{Properties: make(map[string]*property, 0)}
+				r.readFields(embeddedProperty, ft, required && field.Type.Kind() != reflect.Ptr)
+				delete(r.embedding, ft)
+
+				// the fields of outer struct shadow the promoted fields
+				promoted := make(map[string]bool)
+				for name, property := range embeddedProperty.Properties {
+					if _, ok := p.Properties[name]; !ok {
+						p.Properties[name] = property
+						promoted[name] = true
+					}
+				}
+				for _, name := range embeddedProperty.Required {
+					if promoted[name] {
+						p.Required = append(p.Required, name)
+					}
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fp := r.typeProperty(field.Type)
+		if opts.Contains("string") && (fp.Type == "integer" || fp.Type == "number" || fp.Type == "boolean") {
+			fp.Type = "string"
+		}
+		fieldRequired, err := fp.readSchemaTag(field.Tag.Get("jsonschema"), required && !opts.Contains("omitempty"))
+		if err != nil && r.err == nil {
+			r.err = fmt.Errorf("%s.%s: %v", t.Name(), field.Name, err)
+		}
+
+		p.Properties[name] = fp
+		p.Required = removeString(p.Required, name)
+		if fieldRequired {
+			p.Required = append(p.Required, name)
+		}
+	}
+}
+
+// setNullable allow null besides the type of property
+func (p *property) setNullable() {
+	switch {
+	case p.Ref != nil:
+		ref := *p
+		*p = property{AnyOf: []*property{&ref, {Type: "null"}}}
+	case p.Type != "":
+		p.Types = []string{p.Type, "null"}
+		p.Type = ""
+	default:
+		return
+	}
+	if p.Enum != nil {
+		p.Enum = append(p.Enum, nil)
+	}
+}
+
+// readSchemaTag apply the validations of jsonschema tag to the property, returns whether it is required.
+// items are separated by comma, and \\, in the quoted tag is a comma in value. enum values are separated by |.
+func (p *property) readSchemaTag(tag string, required bool) (bool, error) {
+	if tag == "" {
+		return required, nil
+	}
+	nullable := false
+	for _, item := range splitEscaped(tag, ',') {
+		key, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			key, value = item[:i], item[i+1:]
+		}
+		var err error
+		switch key {
+		case "title":
+			p.Title = value
+		case "description":
+			p.Description = value
+		case "format":
+			p.Format = value
+		case "pattern":
+			p.Pattern = value
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				var enum interface{}
+				if enum, err = p.tagValue(v); err != nil {
+					break
+				}
+				p.Enum = append(p.Enum, enum)
+			}
+		case "default":
+			p.Default, err = p.tagValue(value)
+		case "example":
+			var example interface{}
+			if example, err = p.tagValue(value); err == nil {
+				p.Examples = append(p.Examples, example)
+			}
+		case "minimum":
+			p.Minimum, err = tagNumber(value)
+		case "maximum":
+			p.Maximum, err = tagNumber(value)
+		case "exclusiveMinimum":
+			p.ExclusiveMinimum, err = tagNumber(value)
+		case "exclusiveMaximum":
+			p.ExclusiveMaximum, err = tagNumber(value)
+		case "multipleOf":
+			p.MultipleOf, err = tagNumber(value)
+		case "minLength":
+			p.MinLength, err = tagCount(value)
+		case "maxLength":
+			p.MaxLength, err = tagCount(value)
+		case "minItems":
+			p.MinItems, err = tagCount(value)
+		case "maxItems":
+			p.MaxItems, err = tagCount(value)
+		case "minProperties":
+			p.MinProperties, err = tagCount(value)
+		case "maxProperties":
+			p.MaxProperties, err = tagCount(value)
+		case "uniqueItems":
+			p.UniqueItems, err = tagFlag(value)
+		case "deprecated":
+			p.Deprecated, err = tagFlag(value)
+		case "readOnly":
+			p.ReadOnly, err = tagFlag(value)
+		case "writeOnly":
+			p.WriteOnly, err = tagFlag(value)
+		case "required":
+			required, err = tagFlag(value)
+		case "optional":
+			var optional bool
+			optional, err = tagFlag(value)
+			required = required && !optional
+		case "nullable":
+			nullable, err = tagFlag(value)
+		default:
+			err = fmt.Errorf("unknown jsonschema tag %q", key)
+		}
+		if err != nil {
+			return required, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	if nullable && p.Types == nil && p.AnyOf == nil {
+		p.setNullable()
+	} else if p.Enum != nil && contains(p.Types, "null") {
+		p.Enum = append(p.Enum, nil)
+	}
+	return required, nil
+}
+
+// tagValue convert the text of tag to the json value of property type
+func (p *property) tagValue(text string) (interface{}, error) {
+	t := p.Type
+	for _, typ := range p.Types {
+		if typ != "null" {
+			t = typ
+		}
+	}
+	switch t {
+	case "integer", "number":
+		return tagNumber(text)
+	case "boolean":
+		return strconv.ParseBool(text)
+	}
+	return text, nil
+}
+
+func tagNumber(text string) (json.Number, error) {
+	if _, ok := new(big.Rat).SetString(text); !ok {
+		return "", fmt.Errorf("%q is not a number", text)
+	}
+	return json.Number(text), nil
+}
+
+func tagCount(text string) (*int, error) {
+	i, err := strconv.Atoi(text)
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("%q is not a non-negative integer", text)
+	}
+	return intPtr(i), nil
+}
+
+// tagFlag the flag without value is true
+func tagFlag(text string) (bool, error) {
+	if text == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(text)
+}
+
+// splitEscaped split s by sep, the escaped \sep is kept as sep
+func splitEscaped(s string, sep byte) []string {
+	var items []string
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == sep:
+			sb.WriteByte(sep)
+			i++
+		case s[i] == sep:
+			items = append(items, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return append(items, sb.String())
+}
+
+func removeString(slice []string, s string) []string {
+	for i, v := range slice {
+		if v == s {
+			return append(slice[:i], slice[i+1:]...)
+		}
+	}
+	return slice
+}
+
+var kindMapping = map[reflect.Kind]string{
+	reflect.Bool:    "boolean",
+	reflect.Int:     "integer",
+	reflect.Int8:    "integer",
+	reflect.Int16:   "integer",
+	reflect.Int32:   "integer",
+	reflect.Int64:   "integer",
+	reflect.Uint:    "integer",
+	reflect.Uint8:   "integer",
+	reflect.Uint16:  "integer",
+	reflect.Uint32:  "integer",
+	reflect.Uint64:  "integer",
+	reflect.Float32: "number",
+	reflect.Float64: "number",
+	reflect.String:  "string",
+	reflect.Slice:   "array",
+	reflect.Array:   "array",
+	reflect.Struct:  "object",
+	reflect.Map:     "object",
+}
+
+func getTypeFromMapping(t reflect.Type) (string, string, reflect.Kind) {
+	if v, ok := formatMapping[t.String()]; ok {
+		return v[0], v[1], reflect.String
+	}
+
+	if v, ok := kindMapping[t.Kind()]; ok {
+		return v, "", t.Kind()
+	}
+
+	return "", "", t.Kind()
+}
+
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, tagOptions("")
+}
+
+func (o tagOptions) Contains(optionName string) bool {
+	if len(o) == 0 {
+		return false
+	}
+
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == optionName {
+			return true
+		}
+		s = next
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type reflectAudit struct {
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	Note      string    `json:"note,omitempty"`
+}
+
+type reflectLine struct {
+	Sku      string  `json:"sku" jsonschema:"pattern=^[A-Z]{3}-[0-9]+$"`
+	Quantity int     `json:"quantity" jsonschema:"minimum=1,maximum=99"`
+	Price    float64 `json:"price,string"`
+}
+
+type reflectOrder struct {
+	reflectAudit
+	*reflectOptional
+	ID       int64             `json:"id" jsonschema:"description=order id\\, unique,minimum=1"`
+	Status   string            `json:"status" jsonschema:"enum=NEW|PAID|SHIPPED"`
+	Note     *string           `json:"note" jsonschema:"maxLength=200"`
+	Lines    []reflectLine     `json:"lines" jsonschema:"minItems=1"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Parent   *reflectOrder     `json:"parent,omitempty"`
+	Children []reflectOrder    `json:"children,omitempty"`
+	secret   string
+}
+
+type reflectOptional struct {
+	Coupon string `json:"coupon"`
+}
+
+func Test_Reflect(t *testing.T) {
+	doc, err := Reflect(&reflectOrder{}, 2020)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("unexpected $schema %s", doc.Schema)
+	}
+	props := doc.Properties
+	if _, ok := props["secret"]; ok {
+		t.Error("unexported field should be skipped")
+	}
+	if props["id"].Description != "order id, unique" || props["id"].Minimum != "1" {
+		t.Errorf("id: jsonschema tag is not applied %+v", props["id"])
+	}
+	if len(props["status"].Enum) != 3 {
+		t.Errorf("status: unexpected enum %v", props["status"].Enum)
+	}
+	if note := props["note"]; len(note.Types) != 2 || note.Types[1] != "null" || *note.MaxLength != 200 {
+		t.Errorf("note: outer pointer field should shadow the promoted one and be nullable %+v", note)
+	}
+	if props["createdAt"].Format != "date-time" {
+		t.Errorf("createdAt: unexpected format %s", props["createdAt"].Format)
+	}
+	if props["lines"].Items.Properties["price"].Type != "string" {
+		t.Error("price: the string option should encode the number as string")
+	}
+	if props["tags"].AdditionalProperties.(*property).Type != "string" {
+		t.Error("tags: map values are additionalProperties")
+	}
+	for _, name := range []string{"id", "status", "note", "lines", "createdBy", "createdAt"} {
+		if !contains(doc.Required, name) {
+			t.Errorf("%s should be required in %v", name, doc.Required)
+		}
+	}
+	for _, name := range []string{"tags", "parent", "coupon"} {
+		if contains(doc.Required, name) {
+			t.Errorf("%s should not be required", name)
+		}
+	}
+	if parent := props["parent"]; len(parent.AnyOf) != 2 || parent.AnyOf[0].Ref != &doc.property {
+		t.Errorf("parent: recursive type should reference the root")
+	}
+
+	text, err := doc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := CompileString("reflect.json", string(text))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text)
+	}
+	note := "fragile"
+	order := reflectOrder{
+		reflectAudit: reflectAudit{CreatedBy: "joe", CreatedAt: time.Now()},
+		ID:           7,
+		Status:       "PAID",
+		Note:         &note,
+		Lines:        []reflectLine{{Sku: "ABC-1", Quantity: 2, Price: 9.5}},
+		Children:     []reflectOrder{{ID: 8, Status: "NEW", Lines: []reflectLine{{Sku: "XYZ-2", Quantity: 1}}}},
+	}
+	data, _ := json.Marshal(order)
+	var v interface{}
+	json.Unmarshal(data, &v)
+	if err := schema.Validate(v); err != nil {
+		t.Errorf("%v\n%s", err, data)
+	}
+
+	order.Status = "LOST"
+	data, _ = json.Marshal(order)
+	json.Unmarshal(data, &v)
+	if err := schema.Validate(v); err == nil {
+		t.Error("status out of enum should be invalid")
+	}
+}
+
+func Test_ReflectTagError(t *testing.T) {
+	type bad struct {
+		Count int `jsonschema:"minimum=many"`
+	}
+	if _, err := Reflect(bad{}, 7); err == nil {
+		t.Error("invalid minimum should be reported")
+	}
+	var d SchemaDocument
+	if err := d.Read(bad{}); err == nil {
+		t.Error("invalid minimum should be reported by Read")
+	}
+	if err := d.Compile(bad{}); err == nil {
+		t.Error("invalid minimum should be reported by Compile")
+	}
+}
@@ -0,0 +1,677 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sample modes of SampleOptions
+const (
+	SampleValid    = "valid"    // random instances within the constraints
+	SampleBoundary = "boundary" // valid instances on the edges of the constraints, the lower and upper edge in turn
+	SampleInvalid  = "invalid"  // instances that violate exactly one constraint of a valid instance
+)
+
+// DefaultSampleCount the count of samples when SampleOptions.Count is not set
+const DefaultSampleCount = 5
+
+const (
+	constSampleAttempts = 20 // attempts to generate an instance that passes or fails the validation as expected
+	constSampleMaxDepth = 5  // depth of the recursive $ref, the optional properties and items stop there
+)
+
+// SampleOptions options of Samples
+type SampleOptions struct {
+	Mode  string // SampleValid when empty
+	Count int
+	Seed  int64 // the same seed generates the same samples
+}
+
+// Sample the generated instance of schema
+type Sample struct {
+	Value    interface{} `json:"value"`
+	Valid    bool        `json:"valid"`
+	Mutation string      `json:"mutation,omitempty"` // the violated keyword at json pointer, such as /age: minimum
+}
+
+// CheckSampleMode returns error when the sample mode is unknown
+func CheckSampleMode(mode string) error {
+	switch mode {
+	case "", SampleValid, SampleBoundary, SampleInvalid:
+		return nil
+	}
+	return fmt.Errorf("unsupported sample mode %q", mode)
+}
+
+// Samples generate the instances of compiled schema by options.
+// every instance is checked by the schema, valid ones pass the validation and invalid ones fail.
+func (s *Schema) Samples(opts SampleOptions) ([]*Sample, error) {
+	if err := CheckSampleMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultSampleCount
+	}
+	g := &sampler{rand: rand.New(rand.NewSource(opts.Seed))}
+	samples := make([]*Sample, 0, count)
+	for i := 0; i < count; i++ {
+		var sample *Sample
+		var err error
+		switch opts.Mode {
+		case SampleInvalid:
+			sample, err = g.invalid(s)
+		case SampleBoundary:
+			// the lower edge for the even samples and the upper edge for the odd ones
+			g.edge = 1 - 2*(i%2)
+			sample, err = g.valid(s)
+		default:
+			sample, err = g.valid(s)
+		}
+		if err != nil {
+			return samples, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// sampler generate json values of schema
+type sampler struct {
+	rand *rand.Rand
+	edge int // 0 random, 1 lower edge, -1 upper edge
+}
+
+func (g *sampler) valid(s *Schema) (*Sample, error) {
+	for i := 0; i < constSampleAttempts; i++ {
+		v := g.generate(s, 0)
+		if s.Validate(v) == nil {
+			return &Sample{Value: v, Valid: true}, nil
+		}
+	}
+	return nil, fmt.Errorf("can not generate a valid sample of %s", s.Location)
+}
+
+func (g *sampler) invalid(s *Schema) (*Sample, error) {
+	for i := 0; i < constSampleAttempts; i++ {
+		v := g.generate(s, 0)
+		var mutations []*mutation
+		g.mutations(s, v, "", func(nv interface{}) { v = nv }, &mutations)
+		if len(mutations) == 0 {
+			break
+		}
+		m := mutations[g.rand.Intn(len(mutations))]
+		m.apply()
+		if s.Validate(v) != nil {
+			return &Sample{Value: v, Valid: false, Mutation: m.path + ": " + m.keyword}, nil
+		}
+	}
+	return nil, fmt.Errorf("can not generate an invalid sample of %s", s.Location)
+}
+
+// resolve follow the references of schema
+func resolve(s *Schema) *Schema {
+	for i := 0; i < constSampleMaxDepth && s != nil; i++ {
+		switch {
+		case s.Ref != nil:
+			s = s.Ref
+		case s.RecursiveRef != nil:
+			s = s.RecursiveRef
+		case s.DynamicRef != nil:
+			s = s.DynamicRef
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+func (g *sampler) generate(s *Schema, depth int) interface{} {
+	s = resolve(s)
+	if s == nil || s.Always != nil {
+		return nil
+	}
+	if len(s.Constant) > 0 {
+		return s.Constant[0]
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[g.rand.Intn(len(s.Enum))]
+	}
+	if len(s.Types) == 0 && len(s.Properties) == 0 && len(s.Required) == 0 {
+		// the branch of combinator has the type
+		branches := append(append([]*Schema{}, s.OneOf...), s.AnyOf...)
+		if len(branches) > 0 {
+			return g.generate(branches[g.rand.Intn(len(branches))], depth+1)
+		}
+		if len(s.AllOf) > 0 {
+			return g.generate(s.AllOf[0], depth+1)
+		}
+	}
+
+	switch g.sampleType(s) {
+	case "null":
+		return nil
+	case "boolean":
+		return g.rand.Intn(2) == 0
+	case "integer":
+		return g.number(s, true)
+	case "number":
+		return g.number(s, false)
+	case "array":
+		return g.array(s, depth)
+	case "object":
+		return g.object(s, depth)
+	}
+	return g.string(s)
+}
+
+// sampleType the type to generate, null only when no other type is allowed
+func (g *sampler) sampleType(s *Schema) string {
+	types := make([]string, 0, len(s.Types))
+	for _, t := range s.Types {
+		if t != "null" {
+			types = append(types, t)
+		}
+	}
+	switch {
+	case len(types) > 0:
+		return types[g.rand.Intn(len(types))]
+	case len(s.Types) > 0:
+		return "null"
+	case len(s.Properties) > 0 || len(s.Required) > 0 || s.AdditionalProperties != nil:
+		return "object"
+	case s.Items != nil || s.Items2020 != nil || len(s.PrefixItems) > 0 || s.MinItems >= 0:
+		return "array"
+	case s.Minimum != nil || s.Maximum != nil || s.ExclusiveMinimum != nil || s.ExclusiveMaximum != nil || s.MultipleOf != nil:
+		return "number"
+	}
+	return "string"
+}
+
+// between random int in [min, max], or the edge
+func (g *sampler) between(min, max int) int {
+	switch {
+	case max <= min:
+		return min
+	case g.edge > 0:
+		return min
+	case g.edge < 0:
+		return max
+	}
+	return min + g.rand.Intn(max-min+1)
+}
+
+func (g *sampler) string(s *Schema) string {
+	if sample, ok := formatSamples[s.Format]; ok {
+		return sample(g.rand)
+	}
+	min, max := s.MinLength, s.MaxLength
+	if min < 0 {
+		min = 0
+	}
+	if max < 0 {
+		max = min + 12
+	}
+	if s.Pattern != nil {
+		if re, err := syntax.Parse(s.Pattern.String(), syntax.Perl); err == nil {
+			for i := 0; i < constSampleAttempts; i++ {
+				var sb strings.Builder
+				g.regex(re.Simplify(), &sb)
+				if n := len([]rune(sb.String())); n >= min && n <= max {
+					return sb.String()
+				}
+			}
+		}
+	}
+	if min == 0 && max > 0 && g.edge == 0 {
+		min = 1
+	}
+	return g.letters(g.between(min, max))
+}
+
+const sampleLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (g *sampler) letters(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = sampleLetters[g.rand.Intn(len(sampleLetters))]
+	}
+	return string(b)
+}
+
+// regex write a string that matches the regex
+func (g *sampler) regex(re *syntax.Regexp, sb *strings.Builder) {
+	repeat := func(min, max int) {
+		if max < 0 {
+			max = min + 3
+		}
+		for i, n := 0, min+g.rand.Intn(max-min+1); i < n; i++ {
+			g.regex(re.Sub[0], sb)
+		}
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		sb.WriteRune(g.classRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteByte(sampleLetters[g.rand.Intn(len(sampleLetters))])
+	case syntax.OpCapture:
+		g.regex(re.Sub[0], sb)
+	case syntax.OpStar:
+		repeat(0, 3)
+	case syntax.OpPlus:
+		repeat(1, 3)
+	case syntax.OpQuest:
+		repeat(0, 1)
+	case syntax.OpRepeat:
+		repeat(re.Min, re.Max)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.regex(sub, sb)
+		}
+	case syntax.OpAlternate:
+		g.regex(re.Sub[g.rand.Intn(len(re.Sub))], sb)
+	}
+}
+
+// classRune random rune of the char class ranges, printable ascii is preferred
+func (g *sampler) classRune(ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo < ' ' {
+			lo = ' '
+		}
+		if hi > '~' {
+			hi = '~'
+		}
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+		}
+	}
+	if len(printable) == 0 {
+		printable = ranges
+	}
+	if len(printable) == 0 {
+		return 'a'
+	}
+	i := g.rand.Intn(len(printable)/2) * 2
+	lo, hi := printable[i], printable[i+1]
+	return lo + rune(g.rand.Intn(int(hi-lo)+1))
+}
+
+// number random number in the bounds and multipleOf. the default range is [0, 1000]
+func (g *sampler) number(s *Schema, integer bool) json.Number {
+	step := big.NewRat(1, 100)
+	if integer {
+		step = big.NewRat(1, 1)
+	}
+	if s.MultipleOf != nil {
+		step = new(big.Rat).Set(s.MultipleOf)
+		if integer && !step.IsInt() {
+			step.Mul(step, new(big.Rat).SetInt(step.Denom()))
+		}
+	}
+
+	lo, hi := s.Minimum, s.Maximum
+	if s.ExclusiveMinimum != nil && (lo == nil || s.ExclusiveMinimum.Cmp(lo) >= 0) {
+		lo = new(big.Rat).Add(s.ExclusiveMinimum, step)
+	}
+	if s.ExclusiveMaximum != nil && (hi == nil || s.ExclusiveMaximum.Cmp(hi) <= 0) {
+		hi = new(big.Rat).Sub(s.ExclusiveMaximum, step)
+	}
+	switch {
+	case lo == nil && hi == nil:
+		lo, hi = new(big.Rat), big.NewRat(1000, 1)
+	case lo == nil:
+		lo = new(big.Rat).Sub(hi, big.NewRat(1000, 1))
+	case hi == nil:
+		hi = new(big.Rat).Add(lo, big.NewRat(1000, 1))
+	}
+
+	// the values are k*step in [lo, hi]
+	first := ceilRat(new(big.Rat).Quo(lo, step))
+	last := floorRat(new(big.Rat).Quo(hi, step))
+	if first.Cmp(last) > 0 {
+		return ratText(lo, integer)
+	}
+	k := new(big.Int).Set(first)
+	switch {
+	case g.edge > 0:
+	case g.edge < 0:
+		k.Set(last)
+	default:
+		span := new(big.Int).Sub(last, first)
+		span.Add(span, big.NewInt(1))
+		k.Add(k, new(big.Int).Rand(g.rand, span))
+	}
+	return ratText(new(big.Rat).Mul(new(big.Rat).SetInt(k), step), integer)
+}
+
+func floorRat(r *big.Rat) *big.Int {
+	q, _ := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	return q
+}
+
+func ceilRat(r *big.Rat) *big.Int {
+	q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+func ratText(r *big.Rat, integer bool) json.Number {
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+	if integer {
+		return json.Number(ceilRat(r).String())
+	}
+	text := strings.TrimRight(r.FloatString(12), "0")
+	return json.Number(strings.TrimSuffix(text, "."))
+}
+
+func (g *sampler) array(s *Schema, depth int) []interface{} {
+	var prefix []*Schema
+	var rest *Schema
+	restAllowed := true
+	switch items := s.Items.(type) {
+	case *Schema:
+		rest = items
+	case []*Schema:
+		prefix = items
+		switch additional := s.AdditionalItems.(type) {
+		case *Schema:
+			rest = additional
+		case bool:
+			restAllowed = additional
+		}
+	}
+	if len(s.PrefixItems) > 0 {
+		prefix = s.PrefixItems
+	}
+	if s.Items2020 != nil {
+		rest = s.Items2020
+		restAllowed = rest.Always == nil || *rest.Always
+	}
+
+	min, max := s.MinItems, s.MaxItems
+	if min < 0 {
+		min = 0
+	}
+	if max < 0 {
+		max = min + 3
+	}
+	if depth >= constSampleMaxDepth {
+		max = min
+	}
+	if !restAllowed && max > len(prefix) {
+		max = len(prefix)
+	}
+	n := g.between(min, max)
+	if g.edge == 0 && n == 0 && max > 0 {
+		n = 1
+	}
+
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		item := rest
+		if i < len(prefix) {
+			item = prefix[i]
+		}
+		if i == 0 && s.Contains != nil && item == nil {
+			item = s.Contains
+		}
+		var v interface{}
+		for j := 0; j < constSampleAttempts; j++ {
+			v = g.generate(item, depth+1)
+			if !s.UniqueItems || !containsValue(arr, v) {
+				break
+			}
+		}
+		arr = append(arr, v)
+	}
+	return arr
+}
+
+func containsValue(arr []interface{}, v interface{}) bool {
+	for _, item := range arr {
+		if equals(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *sampler) object(s *Schema, depth int) map[string]interface{} {
+	obj := make(map[string]interface{})
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range s.Required {
+		obj[name] = g.generate(g.propertySchema(s, name), depth+1)
+	}
+	for _, name := range names {
+		if _, ok := obj[name]; ok || depth >= constSampleMaxDepth {
+			continue
+		}
+		// the lower edge has the required properties only, the upper edge has all
+		if g.edge < 0 || g.edge == 0 && g.rand.Intn(2) == 0 {
+			obj[name] = g.generate(s.Properties[name], depth+1)
+		}
+	}
+	for i := 0; s.MinProperties > len(obj) && i < constSampleAttempts; i++ {
+		name := "x" + strconv.Itoa(i)
+		if i < len(names) {
+			name = names[i]
+		}
+		if _, ok := obj[name]; !ok {
+			obj[name] = g.generate(g.propertySchema(s, name), depth+1)
+		}
+	}
+	return obj
+}
+
+// propertySchema the schema of property name, nil allows any value
+func (g *sampler) propertySchema(s *Schema, name string) *Schema {
+	if ps, ok := s.Properties[name]; ok {
+		return ps
+	}
+	for re, ps := range s.PatternProperties {
+		if re.MatchString(name) {
+			return ps
+		}
+	}
+	if ps, ok := s.AdditionalProperties.(*Schema); ok {
+		return ps
+	}
+	return nil
+}
+
+// mutation the change that violates the keyword of schema at path
+type mutation struct {
+	path    string
+	keyword string
+	apply   func()
+}
+
+// mutations collect the mutations of value v of schema s, set replaces v in its parent
+func (g *sampler) mutations(s *Schema, v interface{}, path string, set func(interface{}), mutations *[]*mutation) {
+	s = resolve(s)
+	if s == nil || s.Always != nil {
+		return
+	}
+	add := func(keyword string, nv interface{}) {
+		*mutations = append(*mutations, &mutation{path: path, keyword: keyword, apply: func() { set(nv) }})
+	}
+
+	if len(s.Types) > 0 {
+		add("type", wrongType(s.Types))
+	}
+	if len(s.Enum) > 0 {
+		add("enum", "not-in-enum")
+	}
+	if len(s.Constant) > 0 {
+		add("const", "not-const")
+	}
+
+	switch vv := v.(type) {
+	case string:
+		if s.MinLength > 0 {
+			add("minLength", strings.Repeat("a", s.MinLength-1))
+		}
+		if s.MaxLength >= 0 {
+			add("maxLength", strings.Repeat("a", s.MaxLength+1))
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString("") {
+			add("pattern", "")
+		}
+		if s.Format != "" {
+			add("format", "not-"+s.Format)
+		}
+	case json.Number:
+		one := big.NewRat(1, 1)
+		if s.Minimum != nil {
+			add("minimum", ratText(new(big.Rat).Sub(s.Minimum, one), false))
+		}
+		if s.Maximum != nil {
+			add("maximum", ratText(new(big.Rat).Add(s.Maximum, one), false))
+		}
+		if s.ExclusiveMinimum != nil {
+			add("exclusiveMinimum", ratText(s.ExclusiveMinimum, false))
+		}
+		if s.ExclusiveMaximum != nil {
+			add("exclusiveMaximum", ratText(s.ExclusiveMaximum, false))
+		}
+		if s.MultipleOf != nil {
+			if r, ok := new(big.Rat).SetString(string(vv)); ok {
+				half := new(big.Rat).Quo(s.MultipleOf, big.NewRat(2, 1))
+				add("multipleOf", ratText(r.Add(r, half), false))
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			name := name
+			*mutations = append(*mutations, &mutation{path: path + "/" + escape(name), keyword: "required", apply: func() { delete(vv, name) }})
+		}
+		if additional, ok := s.AdditionalProperties.(bool); ok && !additional {
+			*mutations = append(*mutations, &mutation{path: path, keyword: "additionalProperties", apply: func() { vv["unexpected"] = true }})
+		}
+		if s.MaxProperties >= 0 {
+			*mutations = append(*mutations, &mutation{path: path, keyword: "maxProperties", apply: func() {
+				for i := 0; len(vv) <= s.MaxProperties; i++ {
+					vv["extra"+strconv.Itoa(i)] = true
+				}
+			}})
+		}
+		names := make([]string, 0, len(vv))
+		for name := range vv {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			name := name
+			g.mutations(g.propertySchema(s, name), vv[name], path+"/"+escape(name), func(nv interface{}) { vv[name] = nv }, mutations)
+		}
+	case []interface{}:
+		if s.MinItems > 0 {
+			add("minItems", vv[:s.MinItems-1])
+		}
+		if s.MaxItems >= 0 && len(vv) > 0 {
+			longer := append([]interface{}{}, vv...)
+			for len(longer) <= s.MaxItems {
+				longer = append(longer, vv[0])
+			}
+			add("maxItems", longer)
+		}
+		if s.UniqueItems && len(vv) > 0 {
+			add("uniqueItems", append(append([]interface{}{}, vv...), vv[0]))
+		}
+		for i := range vv {
+			i := i
+			g.mutations(itemSchema(s, i), vv[i], path+"/"+strconv.Itoa(i), func(nv interface{}) { vv[i] = nv }, mutations)
+		}
+	}
+}
+
+// itemSchema the schema of the item at index i
+func itemSchema(s *Schema, i int) *Schema {
+	if i < len(s.PrefixItems) {
+		return s.PrefixItems[i]
+	}
+	if s.Items2020 != nil {
+		return s.Items2020
+	}
+	switch items := s.Items.(type) {
+	case *Schema:
+		return items
+	case []*Schema:
+		if i < len(items) {
+			return items[i]
+		}
+		if additional, ok := s.AdditionalItems.(*Schema); ok {
+			return additional
+		}
+	}
+	return nil
+}
+
+// wrongType a value of none of the types
+func wrongType(types []string) interface{} {
+	candidates := []interface{}{"wrong-type", json.Number("12345"), true, map[string]interface{}{}, []interface{}{}}
+	for _, c := range candidates {
+		t := jsonType(c)
+		if !contains(types, t) && !(t == "number" && contains(types, "integer")) {
+			return c
+		}
+	}
+	return nil
+}
+
+// formatSamples generate the sample string of format
+var formatSamples = map[string]func(*rand.Rand) string{
+	"date-time": func(r *rand.Rand) string { return sampleTime(r).Format(time.RFC3339) },
+	"date":      func(r *rand.Rand) string { return sampleTime(r).Format("2006-01-02") },
+	"time":      func(r *rand.Rand) string { return sampleTime(r).Format("15:04:05Z") },
+	"duration":  func(r *rand.Rand) string { return fmt.Sprintf("P%dDT%dH", r.Intn(30), r.Intn(24)) },
+	"email":     func(r *rand.Rand) string { return fmt.Sprintf("user%d@example.com", r.Intn(10000)) },
+	"hostname":  func(r *rand.Rand) string { return fmt.Sprintf("host%d.example.com", r.Intn(10000)) },
+	"ipv4": func(r *rand.Rand) string {
+		return fmt.Sprintf("10.%d.%d.%d", r.Intn(256), r.Intn(256), 1+r.Intn(254))
+	},
+	"ipv6": func(r *rand.Rand) string { return fmt.Sprintf("2001:db8::%x", r.Intn(0xffff)) },
+	"uri":  func(r *rand.Rand) string { return fmt.Sprintf("https://example.com/items/%d", r.Intn(10000)) },
+	"iri":  func(r *rand.Rand) string { return fmt.Sprintf("https://example.com/items/%d", r.Intn(10000)) },
+	"uri-reference": func(r *rand.Rand) string {
+		return fmt.Sprintf("/items/%d", r.Intn(10000))
+	},
+	"iri-reference": func(r *rand.Rand) string { return fmt.Sprintf("/items/%d", r.Intn(10000)) },
+	"uri-template":  func(r *rand.Rand) string { return "https://example.com/items/{id}" },
+	"json-pointer":  func(r *rand.Rand) string { return fmt.Sprintf("/items/%d", r.Intn(100)) },
+	"relative-json-pointer": func(r *rand.Rand) string {
+		return fmt.Sprintf("%d/items", r.Intn(3))
+	},
+	"regex": func(r *rand.Rand) string { return "^[a-z]+$" },
+	"uuid": func(r *rand.Rand) string {
+		b := make([]byte, 16)
+		r.Read(b)
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	},
+}
+
+// sampleTime random time of 2020s in UTC
+func sampleTime(r *rand.Rand) time.Time {
+	return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(r.Int63n(int64(5 * 365 * 24 * time.Hour))))
+}
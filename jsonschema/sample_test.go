@@ -0,0 +1,96 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "code", "status", "price", "lines", "createdAt"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1, "maximum": 99},
+		"code": {"type": "string", "pattern": "^[A-Z]{2}[0-9]{4,6}$"},
+		"status": {"enum": ["NEW", "PAID"]},
+		"price": {"type": "number", "exclusiveMinimum": 0, "maximum": 500, "multipleOf": 0.25},
+		"note": {"type": ["string", "null"], "minLength": 2, "maxLength": 8},
+		"createdAt": {"type": "string", "format": "date-time"},
+		"owner": {"type": "string", "format": "email"},
+		"lines": {"type": "array", "minItems": 1, "maxItems": 3, "uniqueItems": true, "items": {"$ref": "#/$defs/line"}},
+		"point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "items": false}
+	},
+	"$defs": {
+		"line": {
+			"type": "object",
+			"required": ["sku"],
+			"properties": {
+				"sku": {"type": "string", "format": "uuid"},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/line"}}
+			}
+		}
+	}
+}`
+
+func Test_Samples(t *testing.T) {
+	c := NewCompiler()
+	c.AssertFormat = true
+	if err := c.AddResource("sample.json", strings.NewReader(sampleSchema)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := c.Compile("sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{SampleValid, SampleBoundary, SampleInvalid} {
+		samples, err := schema.Samples(SampleOptions{Mode: mode, Count: 10, Seed: 7})
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(samples) != 10 {
+			t.Fatalf("%s: %d samples", mode, len(samples))
+		}
+		for _, sample := range samples {
+			// the samples survive the json round trip
+			text, _ := json.Marshal(sample.Value)
+			var v interface{}
+			json.Unmarshal(text, &v)
+			err := schema.Validate(v)
+			if sample.Valid != (mode != SampleInvalid) || sample.Valid != (err == nil) {
+				t.Errorf("%s: valid %v, validation %v, mutation %q\n%s", mode, sample.Valid, err, sample.Mutation, text)
+			}
+			if mode == SampleInvalid && sample.Mutation == "" {
+				t.Errorf("invalid sample should tell the mutation")
+			}
+		}
+	}
+
+	boundary, _ := schema.Samples(SampleOptions{Mode: SampleBoundary, Count: 2})
+	lower, upper := boundary[0].Value.(map[string]interface{}), boundary[1].Value.(map[string]interface{})
+	if lower["id"] != json.Number("1") || upper["id"] != json.Number("99") {
+		t.Errorf("id should be on the edges: %v %v", lower["id"], upper["id"])
+	}
+	if lower["price"] != json.Number("0.25") || upper["price"] != json.Number("500") {
+		t.Errorf("price should be on the edges: %v %v", lower["price"], upper["price"])
+	}
+	if len(lower["lines"].([]interface{})) != 1 || len(upper["lines"].([]interface{})) != 3 {
+		t.Error("lines should have the min and max items")
+	}
+	if _, ok := lower["note"]; ok {
+		t.Error("the lower edge has the required properties only")
+	}
+
+	x, _ := schema.Samples(SampleOptions{Seed: 42})
+	y, _ := schema.Samples(SampleOptions{Seed: 42})
+	if !reflect.DeepEqual(x, y) {
+		t.Error("the same seed should generate the same samples")
+	}
+
+	if _, err := schema.Samples(SampleOptions{Mode: "fuzzy"}); err == nil {
+		t.Error("unsupported mode should be reported")
+	}
+}
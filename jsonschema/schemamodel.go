@@ -1,13 +1,11 @@
 package jsonschema
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
-	"net/mail"
-	"net/url"
 	"strconv"
-	"time"
+	"strings"
 )
 
 // SchemaDataModel add reader
@@ -18,7 +16,18 @@ type SchemaDataModel struct {
 	Name        string
 	Format      bool
 	Convert     bool
+	Reuse       bool // hoist the repeated sub schemas into $defs
+	Pattern     bool // learn the generalized regex pattern of strings without format, opt-in as a sample pins free text
 	Document    *SchemaDocument
+
+	// ordered formats to detect when Format is true, DefaultDetectFormats when nil
+	DetectFormats []string
+
+	// numeric inference, NumericExact when empty
+	NumericMode    string
+	NumericPadding float64 // padding ratio of the value magnitude in NumericRange mode
+	MultipleOf     bool    // detect the decimal step of numbers as multipleOf
+	Percentiles    bool    // keep the number samples as examples and annotate x-percentiles
 }
 
 // NewSchemaDataModel create schema model
@@ -43,6 +52,12 @@ func NewSchemaDataModel(data []byte, modelName string) *SchemaDataModel {
 	}
 }
 
+// SetDraft set the draft version of generated schema, such as 4, 6, 7, 2019, 2020
+func (m *SchemaDataModel) SetDraft(version int) {
+	_, textSchema := readDraft(version)
+	m.Document.Schema = textSchema
+}
+
 // SchemaGetModel from bytes
 func SchemaGetModel(url string) (*SchemaDataModel, error) {
 	b, name, err := Get(url)
@@ -60,17 +75,37 @@ func GenerateSchemaDataModel(f []byte, name string) (*SchemaDataModel, error) {
 	return m, err
 }
 
+// GenerateSchemaDataModelByDraft use json to generate schema of the draft version
+func GenerateSchemaDataModelByDraft(f []byte, name string, version int) (*SchemaDataModel, error) {
+	m := NewSchemaDataModel(f, name)
+	m.SetDraft(version)
+	err := m.generate()
+	return m, err
+}
+
+// Generate use the json data of model to generate schema
+func (m *SchemaDataModel) Generate() error {
+	return m.generate()
+}
+
 func (m *SchemaDataModel) generate() error {
 	if m.Data == nil {
 		return errors.New("data is empty")
 	}
 
-	jsonData, err := ParseJson(m.Data)
+	jsonData, err := ParseJsonNumber(m.Data)
 	if err != nil {
 		return err
 	}
 
-	return m.parse(jsonData, "", &m.Document.property)
+	err = m.parse(jsonData, "", &m.Document.property)
+	if err != nil {
+		return err
+	}
+	if m.Reuse {
+		m.Document.ExtractDefs()
+	}
+	return nil
 }
 
 // pase object.
@@ -80,10 +115,16 @@ func (m *SchemaDataModel) parse(data interface{}, keyName string, p *property) e
 		m.parseString(vv, keyName, p)
 	case bool:
 		m.parseBool(vv, keyName, p)
+	case json.Number:
+		if isIntegerText(vv) {
+			m.parseInteger(vv, keyName, p)
+		} else {
+			m.parseNumber(vv, keyName, p)
+		}
 	case float64:
-		m.parseNumber(vv, keyName, p)
+		m.parseNumber(floatNumber(vv), keyName, p)
 	case int64:
-		m.parseInteger(vv, keyName, p)
+		m.parseInteger(json.Number(strconv.FormatInt(vv, 10)), keyName, p)
 	case []interface{}:
 		m.parseArray(vv, keyName, p)
 	case map[string]interface{}:
@@ -110,18 +151,19 @@ func (m *SchemaDataModel) parseMap(ms map[string]interface{}, keyName string, p
 	}
 }
 
-func (m *SchemaDataModel) parseInteger(vv int64, keyName string, p *property) {
-	//json parser always returns a float for number values, check if it is an int value
+func (m *SchemaDataModel) parseInteger(vv json.Number, keyName string, p *property) {
 	p.Type = "integer"
-	p.Maximum = float64(vv)
-	p.Minimum = float64(vv)
+	m.inferNumber(vv, true, p)
 }
 
-func (m *SchemaDataModel) parseNumber(vv float64, keyName string, p *property) {
-	//json parser always returns a float for number values, check if it is an int value
+func (m *SchemaDataModel) parseNumber(vv json.Number, keyName string, p *property) {
 	p.Type = "number"
-	p.Maximum = vv
-	p.Minimum = vv
+	m.inferNumber(vv, false, p)
+}
+
+// isIntegerText number without fraction and exponent, such as -12 or 9007199254740993
+func isIntegerText(n json.Number) bool {
+	return !strings.ContainsAny(string(n), ".eE")
 }
 
 func (m *SchemaDataModel) parseBool(vv bool, keyName string, p *property) {
@@ -136,63 +178,74 @@ func (m *SchemaDataModel) parseString(vv string, keyName string, p *property) {
 	fillingGeneralString := func(cv string, types string, format string, p *property) {
 		p.Type = types
 		p.Format = format
-		p.MaxLength = len(vv)
-		p.MinLength = len(vv)
+		p.MaxLength = intPtr(len(vv))
+		p.MinLength = intPtr(len(vv))
 
-		if p.MaxLength < constNotEnumMaxLength {
+		if len(vv) < constNotEnumMaxLength {
 			p.Examples = append(p.Examples, vv)
 		}
-	}
-
-	parseStringInlineFormat := func(value string) (string, string) {
-		if ok := isDate(value); ok {
-			return getTypeFormatByMapping("date")
-		} else if ok := isDateTime(value); ok {
-			return getTypeFormatByMapping("time.Time")
-		} else if _, err := time.Parse(time.RFC3339, value); err == nil {
-			return getTypeFormatByMapping("time.Time")
-		} else if ip := net.ParseIP(value); ip != nil {
-			if ip.To4() != nil {
-				return getTypeFormatByMapping("ipv4")
-			}
-			return getTypeFormatByMapping("ipv6")
-		} else if _, err := mail.ParseAddress(value); err == nil {
-			return getTypeFormatByMapping("email")
-		} else if _, err := url.Parse(value); err == nil {
-			return getTypeFormatByMapping("url")
-		} else if ok := isURI(value); ok {
-			return getTypeFormatByMapping("uri")
-		} else if ok := isURIReference(value); ok {
-			return getTypeFormatByMapping("uri-reference")
-		} else if ok := isJSONPointer(value); ok {
-			return getTypeFormatByMapping("json-pointer")
-		} else if ok := isRelativeJSONPointer(value); ok {
-			return getTypeFormatByMapping("relative-json-pointer")
-		} else if ok := isRegex(value); ok {
-			return getTypeFormatByMapping("regex")
-		} else if _, err := strconv.ParseBool(value); err == nil {
-			return "string", ""
-			// TODO return "string", "bool"=> enum("true","false")
-		} else {
-			return "string", ""
+		if format == "" && m.Pattern {
+			p.Pattern = inferPattern(vv)
 		}
 	}
 
-	subType, format := parseStringInlineFormat(vv)
-	fillingGeneralString(vv, subType, format, p)
+	format := ""
+	if m.Format {
+		format = detectFormat(vv, m.DetectFormats)
+	}
+	fillingGeneralString(vv, "string", format, p)
 }
 
+// parseArray merge the schemas of all the elements as items.
+// a short array of different types in fixed positions is a tuple of prefixItems.
 func (m *SchemaDataModel) parseArray(vv []interface{}, keyName string, p *property) {
 	p.Type = "array"
-	p.MinItems = len(vv)
-	p.MaxItems = len(vv)
-	if len(vv) > 0 {
+	p.UniqueItems = isUniqueScalars(vv)
+	if len(vv) == 0 {
+		return
+	}
+	p.MinItems = intPtr(1)
+
+	items := make([]*property, 0, len(vv))
+	for _, v := range vv {
 		subProp := &property{}
-		p.Items = subProp
-		m.parse(vv[0], keyName, subProp)
+		m.parse(v, keyName, subProp)
+		items = append(items, subProp)
 	}
+	if len(vv) > 1 && len(vv) <= constTupleMaxLength && len(itemTypes(items)) > 1 {
+		p.PrefixItems = items
+		p.Items = &property{Always: new(bool)}
+		p.MinItems = intPtr(len(vv))
+		p.MaxItems = intPtr(len(vv))
+		return
+	}
+	// the conflicting fields of the elements keep the type of the first element
+	p.Items, _ = mergeItems(items)
+}
+
+// isUniqueScalars the array has only distinct strings, numbers and booleans.
+// the array of less than 2 elements tells nothing about uniqueness
+func isUniqueScalars(vv []interface{}) bool {
+	if len(vv) < 2 {
+		return false
+	}
+	seen := make(map[interface{}]bool, len(vv))
+	for _, v := range vv {
+		switch v.(type) {
+		case string, json.Number, float64, int64, bool:
+		default:
+			return false
+		}
+		if seen[v] {
+			return false
+		}
+		seen[v] = true
+	}
+	return true
 }
 
+const constTupleMaxLength = 8
+
 const constNotEnumMaxLength = 20
 
 var formatMapping = map[string][]string{
@@ -204,6 +257,8 @@ var formatMapping = map[string][]string{
 	"email":                 {"string", "email"},
 	"idn-email":             {"string", "idn-email"},
 	"hostname":              {"string", "hostname"},
+	"uuid":                  {"string", "uuid"},
+	"duration":              {"string", "duration"},
 	"idn-hostname":          {"string", "idn-hostname"},
 	"uri":                   {"string", "uri"},
 	"uri-reference":         {"string", "uri-reference"},